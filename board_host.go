@@ -0,0 +1,47 @@
+//go:build !tinygo
+
+package main
+
+import (
+	"os"
+
+	"github.com/conejoninja/vision/settings"
+	"github.com/conejoninja/vision/sim"
+)
+
+// settingsFile is where the simulator keeps its settings between runs.
+const settingsFile = "vision.settings"
+
+// setupBoard runs the headset in the terminal: the LEDs and the OLED are
+// drawn on stdout and the keyboard stands in for the sensors and buttons.
+func setupBoard() error {
+	restore, err := sim.RawMode()
+	if err != nil {
+		return err
+	}
+
+	screen := sim.NewScreen(os.Stdout)
+	keyboard := sim.NewKeyboard(os.Stdin, 6)
+	go func() {
+		<-keyboard.Quit()
+		screen.Close()
+		restore()
+		os.Exit(0)
+	}()
+
+	storage = settings.FileStorage{Path: settingsFile}
+	display = sim.NewOLED(screen, 128, 64)
+	strip = sim.NewStrip(screen)
+	sensor = keyboard
+	accel = keyboard
+	buttons = keyboard
+	joystick = keyboard
+	return nil
+}
+
+// failed says why the simulator could not start, usually because stdin is
+// not a terminal, and quits. There is no OLED to show it on yet.
+func failed(err error) {
+	os.Stderr.WriteString("vision: failed to configure: " + err.Error() + "\n")
+	os.Exit(1)
+}
@@ -0,0 +1,96 @@
+//go:build tinygo
+
+package main
+
+import (
+	"machine"
+	"time"
+
+	"github.com/conejoninja/vision/hal"
+	"github.com/conejoninja/vision/logging"
+	"tinygo.org/x/drivers"
+	"tinygo.org/x/drivers/lsm303agr"
+	"tinygo.org/x/drivers/ssd1306"
+	"tinygo.org/x/drivers/ws2812"
+)
+
+var neo machine.Pin = machine.A1
+
+// setupBoard brings up the real hardware and wires it into the hal globals.
+// The display is ready as soon as it returns, even when a later device fails.
+func setupBoard() error {
+	//waitSerial()
+
+	machine.InitADC()
+
+	machine.I2C0.Configure(machine.I2CConfig{
+		Frequency: machine.TWI_FREQ_400KHZ,
+	})
+
+	oled := ssd1306.NewI2C(machine.I2C0)
+	oled.Configure(ssd1306.Config{
+		Address:  0x3C,
+		Width:    128,
+		Height:   64,
+		Rotation: drivers.Rotation180,
+	})
+	display = oled
+
+	showMessage("BOOT UP...")
+
+	storage = flashStorage{}
+
+	lsm := lsm303agr.New(machine.I2C0)
+	err := lsm.Configure(lsm303agr.Configuration{}) //default settings
+	if err != nil {
+		return err
+	}
+	sensor = lsm
+	accel = lsm
+
+	neo.Configure(machine.PinConfig{Mode: machine.PinOutput})
+	strip = ws2812.NewWS2812(neo)
+
+	gpioPins := []machine.Pin{
+		machine.GPIO1,
+		machine.GPIO0,
+		machine.GPIO16,
+		machine.GPIO15,
+		machine.GPIO25,
+		machine.GPIO26,
+	}
+	pins := make(hal.PinButtons, len(gpioPins))
+	for c := range gpioPins {
+		gpioPins[c].Configure(machine.PinConfig{Mode: machine.PinInputPullup})
+		pins[c] = gpioPins[c]
+	}
+	buttons = pins
+
+	//jx := machine.ADC{machine.A2}
+	jx := machine.ADC{Pin: machine.A3}
+	jy := machine.ADC{Pin: machine.A2}
+	jx.Configure(machine.ADCConfig{})
+	jy.Configure(machine.ADCConfig{})
+	joystick = hal.AnalogJoystick{X: jx, Y: jy}
+
+	return nil
+}
+
+// failed shows that the board could not be set up and keeps saying why on
+// the serial port. The display is always there by then.
+func failed(err error) {
+	showMessage("FAILED")
+	for {
+		if boardLog.Enabled(logging.Error) {
+			boardLog.Error("failed to configure", err.Error())
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// Wait for user to open serial console
+func waitSerial() {
+	for !machine.Serial.DTR() {
+		time.Sleep(100 * time.Millisecond)
+	}
+}
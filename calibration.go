@@ -0,0 +1,89 @@
+package main
+
+import (
+	"strconv"
+
+	"github.com/conejoninja/vision/compass"
+	"github.com/conejoninja/vision/controls"
+	"github.com/conejoninja/vision/logging"
+	"tinygo.org/x/tinyfont"
+)
+
+var (
+	// calibration corrects every magnetometer reading before it is turned
+	// into a heading.
+	calibration = compass.NewCalibration()
+	// calibrating collects readings while the player turns their head
+	// around in CALIBRATING mode.
+	calibrating compass.Calibration
+)
+
+// calibrationMask is the combo that starts and cancels calibration, LEFT and
+// RIGHT held together.
+var calibrationMask = controls.Mask(LEFT, RIGHT)
+
+func calibrationCombo() bool {
+	return hasCombo(calibrationMask)
+}
+
+func startCalibration() {
+	calibrating.Reset()
+	mode = CALIBRATING
+}
+
+// updateCalibration runs one frame of CALIBRATING mode. MID keeps the new
+// calibration once every axis has seen enough of the field, the combo again
+// leaves without changing anything.
+func updateCalibration(x, y, z int32) {
+	calibrating.Add(x, y, z)
+
+	done := "KEEP TURNING"
+	if calibrating.Valid() {
+		done = "MID: DONE"
+	}
+	showLines("CALIBRATING",
+		"TURN IN ALL DIRECTIONS",
+		"X "+strconv.Itoa(int(calibrating.Max[0]-calibrating.Min[0]))+
+			" Y "+strconv.Itoa(int(calibrating.Max[1]-calibrating.Min[1]))+
+			" Z "+strconv.Itoa(int(calibrating.Max[2]-calibrating.Min[2])),
+		done)
+
+	if calibrationCombo() {
+		cancelCalibration()
+		return
+	}
+	if pressedBtn[MID] {
+		finishCalibration()
+	}
+}
+
+// finishCalibration keeps the new calibration, if it is good enough.
+func finishCalibration() {
+	if !calibrating.Valid() {
+		return
+	}
+	calibration = calibrating
+	saveSettings()
+	ox, oy, oz := calibration.Offsets()
+	if calibLog.Enabled(logging.Info) {
+		calibLog.Info("offsets", strconv.Itoa(int(ox)), strconv.Itoa(int(oy)), strconv.Itoa(int(oz)))
+	}
+	showMessage("CALIBRATED")
+	mode = IDLE
+}
+
+// cancelCalibration leaves CALIBRATING without changing anything.
+func cancelCalibration() {
+	showMessage("CANCELLED")
+	mode = IDLE
+}
+
+// showLines writes a few centred lines of text on the OLED.
+func showLines(lines ...string) {
+	display.ClearDisplay()
+	for i, line := range lines {
+		_, w := tinyfont.LineWidth(&tinyfont.Org01, line)
+		tinyfont.WriteLine(display, &tinyfont.Org01, int16(128-w)/2, int16(16+12*i), line, colors[WHITE])
+	}
+	display.Display()
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"image/color"
+	"math"
+	"strconv"
+
+	"github.com/conejoninja/vision/telemetry"
+)
+
+// circleShrink is how fast the circle closes in, in radius units per second.
+const circleShrink = 20
+
+func init() {
+	registerGame(CIRCLE, &circleGame{})
+}
+
+// circleGame closes an ever growing arc around the player, who has to look
+// at the gap before the circle shrinks onto them.
+type circleGame struct {
+	arc, orientation byte
+	radius           float64
+	heading          int
+}
+
+func (g *circleGame) Name() string { return "CIRCLE" }
+
+func (g *circleGame) Init() {
+	g.arc = NUMLEDS
+	g.orientation = byte(randomInt(0, NUMLEDS*2))
+	g.radius = 300
+}
+
+// index returns the LED covered by the i-th piece of the arc, or -1 when it
+// falls outside of the strip.
+func (g *circleGame) index(i byte) int {
+	idx := i + g.orientation + byte(g.heading)
+	idx %= (NUMLEDS * 2)
+	if idx > 0 && idx < NUMLEDS {
+		return int(idx)
+	}
+	return -1
+}
+
+func (g *circleGame) Update(in *Input) {
+	g.heading = in.Heading + in.OffsetHeading
+
+	success := true
+	for i := byte(0); i < g.arc; i++ {
+		if g.index(i) == 13 {
+			success = false
+		}
+	}
+
+	g.radius -= circleShrink * in.DT.Seconds()
+	if g.radius < 56 {
+		if !success {
+			switchGame(GAMEOVER)
+			return
+		}
+		g.arc++
+		g.radius = 300
+		g.orientation = byte(randomInt(0, NUMLEDS*2))
+	}
+}
+
+func (g *circleGame) Render(leds []color.RGBA) {
+	brightness := 300 - int(g.radius)
+	if brightness < 0 {
+		brightness = 0
+	} else if brightness > 255 {
+		brightness = 255
+	}
+	// gamma correction
+	brightness = int(math.Pow(float64(brightness)/255, 2.5) * 255)
+
+	c := shade(colors[BLUE], brightness)
+	for i := byte(0); i < g.arc; i++ {
+		if idx := g.index(i); idx >= 0 {
+			leds[idx] = c
+		}
+	}
+	//leds[13] = colors[RED]
+}
+
+func (g *circleGame) Telemetry(section []byte) []byte {
+	return telemetry.Circle{Arc: g.arc, Orientation: g.orientation, Radius: int32(g.radius)}.Append(section)
+}
+
+func (g *circleGame) Publish() {
+	data = []byte(strconv.Itoa(int(g.arc)))
+	publishData(circlesArcTopic, &data)
+	data = []byte(strconv.Itoa(int(g.orientation)))
+	publishData(circlesOrientationTopic, &data)
+	radius := int(g.radius)
+	data = []byte{
+		byte(radius >> 24),
+		byte(radius >> 16),
+		byte(radius >> 8),
+		byte(radius),
+	}
+	publishData(circlesRadiusTopic, &data)
+}
@@ -0,0 +1,214 @@
+// Package command parses the remote commands the headset takes over MQTT and
+// hands them from the network goroutine to the game loop.
+//
+// A command is one line of ASCII words, the first one says what to do and
+// case does not matter:
+//
+//	GAME <name>                  switch to the game called name
+//	CENTER                       take the current heading as straight ahead
+//	CALIBRATE [DONE|CANCEL]      start, finish or abandon calibration
+//	BRIGHTNESS <10-100>          LED brightness in percent
+//	COLOR <name> <r> <g> <b>     change a colour of the palette
+//	RESTART                      start the current level again
+//	RACE [START [seed] [size]|LEAVE]
+//	                             join a maze race, start a new one for
+//	                             everyone racing or leave it
+//	ANNOUNCE                     publish the discovery announcement again
+//	MAZE <seed> [size]           play the maze generated from seed, 0 is
+//	                             the classic one
+//	LOG <level> [tag]            log from level up, for everything or only
+//	                             the subsystem tag
+//	LOG SINK <SERIAL|MQTT|NONE>  where the log goes
+package command
+
+import (
+	"errors"
+	"image/color"
+	"strconv"
+	"strings"
+
+	"github.com/conejoninja/vision/logging"
+)
+
+// Kind of command.
+type Kind uint8
+
+const (
+	Game Kind = iota + 1
+	Center
+	Calibrate
+	CalibrateDone
+	CalibrateCancel
+	Brightness
+	Color
+	Restart
+	Maze
+	Announce
+	RaceJoin
+	RaceStart
+	RaceLeave
+	LogLevel
+	LogSink
+)
+
+var (
+	ErrEmpty   = errors.New("command: empty")
+	ErrUnknown = errors.New("command: unknown")
+	ErrArgs    = errors.New("command: wrong arguments")
+)
+
+// Command is a parsed command.
+type Command struct {
+	Kind Kind
+	// Name is the game of Game, the colour of Color, the tag of LogLevel,
+	// empty for every tag, and the sink of LogSink, in upper case.
+	Name string
+	// Value is the percentage of Brightness, the seed of Maze and
+	// RaceStart, 0 for RaceStart picks one at random, and the
+	// logging.Level of LogLevel.
+	Value uint32
+	// Size is the side of the maze for Maze and RaceStart, 0 keeps the
+	// current one.
+	Size int
+	// Color is the new colour of Color.
+	Color color.RGBA
+}
+
+// Parse reads a command from payload.
+func Parse(payload []byte) (Command, error) {
+	words := strings.Fields(strings.ToUpper(string(payload)))
+	if len(words) == 0 {
+		return Command{}, ErrEmpty
+	}
+	args := words[1:]
+	var c Command
+	switch words[0] {
+	case "GAME":
+		if len(args) != 1 {
+			return c, ErrArgs
+		}
+		c.Kind = Game
+		c.Name = args[0]
+	case "CENTER", "CENTRE":
+		if len(args) != 0 {
+			return c, ErrArgs
+		}
+		c.Kind = Center
+	case "CALIBRATE":
+		switch {
+		case len(args) == 0:
+			c.Kind = Calibrate
+		case len(args) == 1 && args[0] == "DONE":
+			c.Kind = CalibrateDone
+		case len(args) == 1 && args[0] == "CANCEL":
+			c.Kind = CalibrateCancel
+		default:
+			return c, ErrArgs
+		}
+	case "BRIGHTNESS":
+		if len(args) != 1 {
+			return c, ErrArgs
+		}
+		v, err := strconv.ParseUint(args[0], 10, 8)
+		if err != nil || v < 10 || v > 100 {
+			return c, ErrArgs
+		}
+		c.Kind = Brightness
+		c.Value = uint32(v)
+	case "COLOR", "COLOUR":
+		if len(args) != 4 {
+			return c, ErrArgs
+		}
+		var rgb [3]uint8
+		for i := range rgb {
+			v, err := strconv.ParseUint(args[1+i], 10, 8)
+			if err != nil {
+				return c, ErrArgs
+			}
+			rgb[i] = uint8(v)
+		}
+		c.Kind = Color
+		c.Name = args[0]
+		c.Color = color.RGBA{rgb[0], rgb[1], rgb[2], 255}
+	case "RESTART":
+		if len(args) != 0 {
+			return c, ErrArgs
+		}
+		c.Kind = Restart
+	case "ANNOUNCE":
+		if len(args) != 0 {
+			return c, ErrArgs
+		}
+		c.Kind = Announce
+	case "MAZE":
+		if len(args) < 1 {
+			return c, ErrArgs
+		}
+		if err := parseMaze(&c, args); err != nil {
+			return c, err
+		}
+		c.Kind = Maze
+	case "RACE":
+		switch {
+		case len(args) == 0:
+			c.Kind = RaceJoin
+		case args[0] == "LEAVE" && len(args) == 1:
+			c.Kind = RaceLeave
+		case args[0] == "START":
+			if err := parseMaze(&c, args[1:]); err != nil {
+				return c, err
+			}
+			c.Kind = RaceStart
+		default:
+			return c, ErrArgs
+		}
+	case "LOG":
+		switch {
+		case len(args) == 2 && args[0] == "SINK":
+			switch args[1] {
+			case "SERIAL", "MQTT", "NONE":
+			default:
+				return c, ErrArgs
+			}
+			c.Kind = LogSink
+			c.Name = args[1]
+		case len(args) == 1 || len(args) == 2:
+			level, ok := logging.ParseLevel(args[0])
+			if !ok {
+				return c, ErrArgs
+			}
+			c.Kind = LogLevel
+			c.Value = uint32(level)
+			if len(args) == 2 {
+				c.Name = args[1]
+			}
+		default:
+			return c, ErrArgs
+		}
+	default:
+		return c, ErrUnknown
+	}
+	return c, nil
+}
+
+// parseMaze reads the optional seed and size of a maze into c.
+func parseMaze(c *Command, args []string) error {
+	if len(args) > 2 {
+		return ErrArgs
+	}
+	if len(args) > 0 {
+		seed, err := strconv.ParseUint(args[0], 10, 32)
+		if err != nil {
+			return ErrArgs
+		}
+		c.Value = uint32(seed)
+	}
+	if len(args) > 1 {
+		size, err := strconv.Atoi(args[1])
+		if err != nil || size < 5 || size > 63 {
+			return ErrArgs
+		}
+		c.Size = size
+	}
+	return nil
+}
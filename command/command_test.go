@@ -0,0 +1,100 @@
+package command
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/conejoninja/vision/logging"
+)
+
+func TestParse(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		want Command
+		err  error
+	}{
+		{"", Command{}, ErrEmpty},
+		{"  \t ", Command{}, ErrEmpty},
+		{"JUMP", Command{}, ErrUnknown},
+
+		{"game maze", Command{Kind: Game, Name: "MAZE"}, nil},
+		{"GAME", Command{}, ErrArgs},
+		{"GAME maze circle", Command{}, ErrArgs},
+		{"Center", Command{Kind: Center}, nil},
+		{"CENTRE", Command{Kind: Center}, nil},
+		{"CENTER now", Command{}, ErrArgs},
+		{"RESTART", Command{Kind: Restart}, nil},
+		{"RESTART 1", Command{}, ErrArgs},
+		{"ANNOUNCE", Command{Kind: Announce}, nil},
+		{"ANNOUNCE all", Command{}, ErrArgs},
+
+		{"CALIBRATE", Command{Kind: Calibrate}, nil},
+		{"calibrate done", Command{Kind: CalibrateDone}, nil},
+		{"CALIBRATE CANCEL", Command{Kind: CalibrateCancel}, nil},
+		{"CALIBRATE NOW", Command{}, ErrArgs},
+		{"CALIBRATE DONE CANCEL", Command{}, ErrArgs},
+
+		{"BRIGHTNESS 9", Command{}, ErrArgs},
+		{"BRIGHTNESS 10", Command{Kind: Brightness, Value: 10}, nil},
+		{"BRIGHTNESS 100", Command{Kind: Brightness, Value: 100}, nil},
+		{"BRIGHTNESS 101", Command{}, ErrArgs},
+		{"BRIGHTNESS 300", Command{}, ErrArgs},
+		{"BRIGHTNESS -50", Command{}, ErrArgs},
+		{"BRIGHTNESS", Command{}, ErrArgs},
+		{"BRIGHTNESS 50 60", Command{}, ErrArgs},
+
+		{"color red 255 0 10", Command{Kind: Color, Name: "RED", Color: color.RGBA{255, 0, 10, 255}}, nil},
+		{"COLOUR BLUE 0 0 0", Command{Kind: Color, Name: "BLUE", Color: color.RGBA{0, 0, 0, 255}}, nil},
+		// the palette is the game's, whether a name is in it is checked
+		// when the command is run
+		{"COLOR PINK 1 2 3", Command{Kind: Color, Name: "PINK", Color: color.RGBA{1, 2, 3, 255}}, nil},
+		{"COLOR RED 256 0 0", Command{}, ErrArgs},
+		{"COLOR RED 0 -1 0", Command{}, ErrArgs},
+		{"COLOR RED 0 0 x", Command{}, ErrArgs},
+		{"COLOR RED 0 0", Command{}, ErrArgs},
+		{"COLOR RED 0 0 0 0", Command{}, ErrArgs},
+
+		{"MAZE 0", Command{Kind: Maze}, nil},
+		{"MAZE 1234", Command{Kind: Maze, Value: 1234}, nil},
+		{"MAZE 4294967295", Command{Kind: Maze, Value: 4294967295}, nil},
+		{"MAZE 4294967296", Command{}, ErrArgs},
+		{"MAZE 7 4", Command{}, ErrArgs},
+		{"MAZE 7 5", Command{Kind: Maze, Value: 7, Size: 5}, nil},
+		{"MAZE 7 63", Command{Kind: Maze, Value: 7, Size: 63}, nil},
+		{"MAZE 7 64", Command{}, ErrArgs},
+		{"MAZE", Command{}, ErrArgs},
+		{"MAZE seven", Command{}, ErrArgs},
+		{"MAZE 7 9 9", Command{}, ErrArgs},
+
+		{"RACE", Command{Kind: RaceJoin}, nil},
+		{"RACE START", Command{Kind: RaceStart}, nil},
+		{"race start 99", Command{Kind: RaceStart, Value: 99}, nil},
+		{"RACE START 99 21", Command{Kind: RaceStart, Value: 99, Size: 21}, nil},
+		{"RACE START 99 4", Command{}, ErrArgs},
+		{"RACE START 99 64", Command{}, ErrArgs},
+		{"RACE START 99 21 1", Command{}, ErrArgs},
+		{"RACE LEAVE", Command{Kind: RaceLeave}, nil},
+		{"RACE LEAVE NOW", Command{}, ErrArgs},
+		{"RACE WIN", Command{}, ErrArgs},
+
+		{"LOG DEBUG", Command{Kind: LogLevel, Value: uint32(logging.Debug)}, nil},
+		{"log warn link", Command{Kind: LogLevel, Value: uint32(logging.Warn), Name: "LINK"}, nil},
+		{"LOG LOUD", Command{}, ErrArgs},
+		{"LOG INFO LINK MAZE", Command{}, ErrArgs},
+		{"LOG", Command{}, ErrArgs},
+		{"LOG SINK serial", Command{Kind: LogSink, Name: "SERIAL"}, nil},
+		{"LOG SINK MQTT", Command{Kind: LogSink, Name: "MQTT"}, nil},
+		{"LOG SINK NONE", Command{Kind: LogSink, Name: "NONE"}, nil},
+		{"LOG SINK FILE", Command{}, ErrArgs},
+		{"LOG SINK", Command{}, ErrArgs},
+	} {
+		got, err := Parse([]byte(tt.in))
+		if err != tt.err {
+			t.Errorf("%q: error %v, want %v", tt.in, err, tt.err)
+			continue
+		}
+		if err == nil && got != tt.want {
+			t.Errorf("%q: got %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
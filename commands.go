@@ -0,0 +1,151 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/conejoninja/vision/command"
+	"github.com/conejoninja/vision/logging"
+	"github.com/conejoninja/vision/queue"
+)
+
+// commands holds what arrived on commandTopic until the game loop runs it.
+var commands = queue.New[command.Command](8)
+
+var (
+	errNoGame   = errors.New("no such game")
+	errNoColour = errors.New("no such colour")
+	errTooMany  = errors.New("too many log tags")
+)
+
+// restarter is implemented by games that can start their level again
+// without starting over.
+type restarter interface {
+	Restart()
+}
+
+// receiveCommand is called from the MQTT reader goroutine, it only parses
+// and queues.
+func receiveCommand(payload []byte) {
+	c, err := command.Parse(payload)
+	if err != nil {
+		if commandLog.Enabled(logging.Warn) {
+			commandLog.Warn(string(payload), err.Error())
+		}
+		return
+	}
+	if !commands.Push(c) && commandLog.Enabled(logging.Warn) {
+		commandLog.Warn("queue full, dropped", string(payload))
+	}
+}
+
+// runCommands runs every queued command, between two frames.
+func runCommands() {
+	for {
+		c, ok := commands.Pop()
+		if !ok {
+			return
+		}
+		if err := runCommand(c); err != nil && commandLog.Enabled(logging.Warn) {
+			commandLog.Warn(err.Error())
+		}
+	}
+}
+
+// runCommand runs c in the game loop, whoever sent it.
+func runCommand(c command.Command) error {
+	switch c.Kind {
+	case command.Game:
+		id, ok := gameByName(c.Name)
+		if !ok {
+			return errNoGame
+		}
+		switchGame(id)
+	case command.Center:
+		centerView()
+		mode = IDLE
+	case command.Calibrate:
+		startCalibration()
+	case command.CalibrateDone:
+		if mode == CALIBRATING {
+			finishCalibration()
+		}
+	case command.CalibrateCancel:
+		if mode == CALIBRATING {
+			cancelCalibration()
+		}
+	case command.Brightness:
+		brightness = int(c.Value)
+		saveSettings()
+	case command.Color:
+		i, ok := colorByName(c.Name)
+		if !ok {
+			return errNoColour
+		}
+		colors[i] = c.Color
+	case command.Restart:
+		if r, ok := games[game].(restarter); ok {
+			r.Restart()
+		} else {
+			games[game].Init()
+		}
+	case command.Maze:
+		leaveRace()
+		if game != MAZE {
+			switchGame(MAZE)
+		}
+		mazeSeed = c.Value
+		if c.Size > 0 {
+			mazeWidth, mazeHeight = c.Size, c.Size
+		}
+		games[MAZE].(restarter).Restart()
+		showLines("REMOTE MAZE", "SEED "+strconv.FormatUint(uint64(mazeSeed), 10))
+	case command.Announce:
+		publishDiscovery()
+	case command.RaceJoin:
+		joinRace()
+	case command.RaceStart:
+		seed := c.Value
+		if seed == 0 {
+			seed = newRaceSeed()
+		}
+		startRace(seed, c.Size)
+	case command.RaceLeave:
+		leaveRace()
+	case command.LogLevel:
+		if c.Name == "" {
+			logger.SetLevel(logging.Level(c.Value))
+		} else if !logger.SetTagLevel(c.Name, logging.Level(c.Value)) {
+			return errTooMany
+		}
+	case command.LogSink:
+		sink, _ := logSinkByName(c.Name)
+		setLogSink(sink)
+	}
+	return nil
+}
+
+// gameByName finds a game the menu would offer.
+func gameByName(name string) (int, bool) {
+	for _, id := range playableGames() {
+		if games[id].Name() == name {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+// colorByName finds a colour of the palette, BLACK stays black.
+func colorByName(name string) (int, bool) {
+	switch name {
+	case "WHITE":
+		return WHITE, true
+	case "RED":
+		return RED, true
+	case "GREEN":
+		return GREEN, true
+	case "BLUE":
+		return BLUE, true
+	}
+	return 0, false
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/conejoninja/vision/command"
+)
+
+func TestUnknownColour(t *testing.T) {
+	newTestBoard(t, NORTH)
+	before := append([]color.RGBA(nil), colors...)
+	c, err := command.Parse([]byte("COLOR PINK 1 2 3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := runCommand(c); err != errNoColour {
+		t.Errorf("unknown colour: %v, want %v", err, errNoColour)
+	}
+	for i := range colors {
+		if colors[i] != before[i] {
+			t.Errorf("an unknown colour changed colour %d", i)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package compass
+
+import (
+	"math"
+)
+
+// MinRange is the smallest spread, in raw sensor units, every axis needs to
+// have seen before a calibration is trusted.
+const MinRange = 200
+
+// Calibration removes hard and soft iron distortion from magnetometer
+// readings. It is built from the extremes seen on each axis while the sensor
+// is turned around in every direction: the middle of each range is the hard
+// iron offset, and scaling every range to the same size undoes the soft iron
+// squashing of the sphere.
+type Calibration struct {
+	Min, Max [3]int32
+}
+
+// NewCalibration returns a calibration that has not seen any reading yet.
+func NewCalibration() Calibration {
+	var c Calibration
+	c.Reset()
+	return c
+}
+
+// Reset forgets every reading seen so far.
+func (c *Calibration) Reset() {
+	for i := range c.Min {
+		c.Min[i] = math.MaxInt32
+		c.Max[i] = math.MinInt32
+	}
+}
+
+// Add widens the ranges with a new raw reading.
+func (c *Calibration) Add(x, y, z int32) {
+	for i, v := range [3]int32{x, y, z} {
+		if v < c.Min[i] {
+			c.Min[i] = v
+		}
+		if v > c.Max[i] {
+			c.Max[i] = v
+		}
+	}
+}
+
+// Valid reports whether every axis has seen at least MinRange.
+func (c *Calibration) Valid() bool {
+	for i := range c.Min {
+		if c.Max[i] < c.Min[i] || int64(c.Max[i])-int64(c.Min[i]) < MinRange {
+			return false
+		}
+	}
+	return true
+}
+
+// Offsets returns the hard iron offset of each axis.
+func (c *Calibration) Offsets() (x, y, z float64) {
+	if !c.Valid() {
+		return 0, 0, 0
+	}
+	return c.offset(0), c.offset(1), c.offset(2)
+}
+
+// Scales returns the soft iron scale factor of each axis.
+func (c *Calibration) Scales() (x, y, z float64) {
+	if !c.Valid() {
+		return 1, 1, 1
+	}
+	avg := (c.span(0) + c.span(1) + c.span(2)) / 3
+	return avg / c.span(0), avg / c.span(1), avg / c.span(2)
+}
+
+func (c *Calibration) offset(i int) float64 {
+	return (float64(c.Max[i]) + float64(c.Min[i])) / 2
+}
+
+func (c *Calibration) span(i int) float64 {
+	return float64(c.Max[i]) - float64(c.Min[i])
+}
+
+// Apply corrects a raw reading. An invalid calibration leaves it untouched.
+func (c *Calibration) Apply(x, y, z int32) (cx, cy, cz float64) {
+	ox, oy, oz := c.Offsets()
+	sx, sy, sz := c.Scales()
+	return (float64(x) - ox) * sx, (float64(y) - oy) * sy, (float64(z) - oz) * sz
+}
@@ -0,0 +1,103 @@
+package compass
+
+import (
+	"math"
+	"testing"
+)
+
+// sweep turns a sensor in every direction through a field whose sphere is
+// moved to center by hard iron and squashed to radius by soft iron.
+func sweep(c *Calibration, center, radius [3]float64) {
+	for i := 0; i <= 36; i++ {
+		phi := math.Pi * float64(i) / 36
+		for j := 0; j < 72; j++ {
+			theta := 2 * math.Pi * float64(j) / 72
+			c.Add(
+				int32(math.Round(center[0]+radius[0]*math.Sin(phi)*math.Cos(theta))),
+				int32(math.Round(center[1]+radius[1]*math.Sin(phi)*math.Sin(theta))),
+				int32(math.Round(center[2]+radius[2]*math.Cos(phi))),
+			)
+		}
+	}
+}
+
+func approx(a, b, tolerance float64) bool {
+	return math.Abs(a-b) <= tolerance
+}
+
+func TestCalibrationOffsetsAndScales(t *testing.T) {
+	c := NewCalibration()
+	center := [3]float64{120, -80, 35}
+	radius := [3]float64{300, 200, 250}
+	sweep(&c, center, radius)
+	if !c.Valid() {
+		t.Fatalf("a full sweep is not valid: %+v", c)
+	}
+
+	ox, oy, oz := c.Offsets()
+	if !approx(ox, 120, 0.5) || !approx(oy, -80, 0.5) || !approx(oz, 35, 0.5) {
+		t.Errorf("offsets %.1f %.1f %.1f, want 120 -80 35", ox, oy, oz)
+	}
+
+	// every axis is scaled to the average span, 500
+	sx, sy, sz := c.Scales()
+	if !approx(sx, 500.0/600, 0.01) || !approx(sy, 500.0/400, 0.01) || !approx(sz, 500.0/500, 0.01) {
+		t.Errorf("scales %.3f %.3f %.3f, want %.3f %.3f %.3f", sx, sy, sz, 500.0/600, 500.0/400, 1.0)
+	}
+}
+
+func TestCalibrationApply(t *testing.T) {
+	c := NewCalibration()
+	sweep(&c, [3]float64{120, -80, 35}, [3]float64{300, 200, 250})
+
+	// the squashed sphere comes out round, with a radius of 250
+	for _, tt := range []struct {
+		x, y, z    int32
+		wx, wy, wz float64
+	}{
+		{120, -80, 35, 0, 0, 0},
+		{420, -80, 35, 250, 0, 0},
+		{-180, -80, 35, -250, 0, 0},
+		{120, 120, 35, 0, 250, 0},
+		{120, -80, -215, 0, 0, -250},
+	} {
+		x, y, z := c.Apply(tt.x, tt.y, tt.z)
+		if !approx(x, tt.wx, 2) || !approx(y, tt.wy, 2) || !approx(z, tt.wz, 2) {
+			t.Errorf("Apply(%d, %d, %d) = %.1f %.1f %.1f, want %.0f %.0f %.0f",
+				tt.x, tt.y, tt.z, x, y, z, tt.wx, tt.wy, tt.wz)
+		}
+	}
+}
+
+func TestCalibrationMinRange(t *testing.T) {
+	c := NewCalibration()
+	if c.Valid() {
+		t.Error("valid before any reading")
+	}
+
+	// one axis short of MinRange
+	c.Add(-100, -100, -100)
+	c.Add(100, 100, -100+MinRange-1)
+	if c.Valid() {
+		t.Errorf("valid with a range of %d", MinRange-1)
+	}
+	if x, y, z := c.Offsets(); x != 0 || y != 0 || z != 0 {
+		t.Errorf("offsets %v %v %v from an invalid calibration", x, y, z)
+	}
+	if x, y, z := c.Scales(); x != 1 || y != 1 || z != 1 {
+		t.Errorf("scales %v %v %v from an invalid calibration", x, y, z)
+	}
+	if x, y, z := c.Apply(7, -8, 9); x != 7 || y != -8 || z != 9 {
+		t.Errorf("invalid calibration changed the reading to %v %v %v", x, y, z)
+	}
+
+	c.Add(0, 0, -100+MinRange)
+	if !c.Valid() {
+		t.Errorf("not valid with a range of %d", MinRange)
+	}
+
+	c.Reset()
+	if c.Valid() {
+		t.Error("valid after Reset")
+	}
+}
@@ -0,0 +1,50 @@
+// Package compass turns raw magnetometer readings into a heading. It has no
+// hardware dependencies so the maths can be checked on a host.
+package compass
+
+import (
+	"math"
+)
+
+// Heading returns the angle, in radians between -π and π, of the horizontal
+// part of the field (x, y, z). z is the vertical axis.
+func Heading(x, y, z float64) float64 {
+	norm := math.Sqrt(x*x + y*y + z*z)
+	if norm == 0 {
+		return 0
+	}
+	x /= norm
+	y /= norm
+	z /= norm
+
+	phi := math.Asin(z)
+	cosPhi := math.Cos(phi)
+
+	return math.Atan2(y*cosPhi, x*cosPhi)
+}
+
+// TiltCompensated returns the heading of the field (mx, my, mz) measured
+// while the sensor is tilted, along with that tilt. The acceleration
+// (ax, ay, az) is taken to be gravity alone, and both vectors use the same
+// axes, with z pointing up when the sensor is level. Pitch grows as the x
+// axis rises above the horizon and roll as the y axis does. All angles are
+// in radians. Without a usable acceleration it falls back to Heading.
+func TiltCompensated(mx, my, mz, ax, ay, az float64) (heading, pitch, roll float64) {
+	norm := math.Sqrt(ax*ax + ay*ay + az*az)
+	if norm == 0 {
+		return Heading(mx, my, mz), 0, 0
+	}
+	ax /= norm
+	ay /= norm
+	az /= norm
+
+	// rotate the field back onto the horizontal plane
+	theta := math.Asin(-ax)
+	phi := math.Atan2(ay, az)
+	sinTheta, cosTheta := math.Sincos(theta)
+	sinPhi, cosPhi := math.Sincos(phi)
+	x := mx*cosTheta + my*sinTheta*sinPhi + mz*sinTheta*cosPhi
+	y := my*cosPhi - mz*sinPhi
+
+	return math.Atan2(y, x), -theta, phi
+}
@@ -0,0 +1,102 @@
+package compass
+
+import (
+	"math"
+	"testing"
+)
+
+// field is the earth's field where the tests are, pointing north and down
+// into the ground.
+func field(heading float64) [3]float64 {
+	return [3]float64{400 * math.Cos(heading), 400 * math.Sin(heading), -300}
+}
+
+func dot(a, b [3]float64) float64 {
+	return a[0]*b[0] + a[1]*b[1] + a[2]*b[2]
+}
+
+// sensor returns what a sensor reads, field and gravity, with its axes at
+// x, y and z in the world.
+func sensor(f [3]float64, x, y, z [3]float64) (m, a [3]float64) {
+	up := [3]float64{0, 0, 9.81}
+	return [3]float64{dot(f, x), dot(f, y), dot(f, z)},
+		[3]float64{dot(up, x), dot(up, y), dot(up, z)}
+}
+
+// pitched raises the x axis by p, rolled raises the y axis by r.
+func pitched(p float64) (x, y, z [3]float64) {
+	s, c := math.Sincos(p)
+	return [3]float64{c, 0, s}, [3]float64{0, 1, 0}, [3]float64{-s, 0, c}
+}
+
+func rolled(r float64) (x, y, z [3]float64) {
+	s, c := math.Sincos(r)
+	return [3]float64{1, 0, 0}, [3]float64{0, c, s}, [3]float64{0, -s, c}
+}
+
+func TestHeading(t *testing.T) {
+	for _, tt := range []struct {
+		x, y, z float64
+		want    float64
+	}{
+		{400, 0, -300, 0},
+		{0, 400, -300, math.Pi / 2},
+		{0, -400, -300, -math.Pi / 2},
+		{-400, 0, -300, math.Pi},
+		{400, 400, 0, math.Pi / 4},
+		{0, 0, 0, 0},
+	} {
+		if got := Heading(tt.x, tt.y, tt.z); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("Heading(%v, %v, %v) = %v, want %v", tt.x, tt.y, tt.z, got, tt.want)
+		}
+	}
+}
+
+func TestTiltCompensated(t *testing.T) {
+	deg := math.Pi / 180
+	for _, tt := range []struct {
+		name                 string
+		heading, pitch, roll float64
+	}{
+		{"level north", 0, 0, 0},
+		{"level east", 90 * deg, 0, 0},
+		{"level south west", -135 * deg, 0, 0},
+		{"nose up", 30 * deg, 25 * deg, 0},
+		{"nose down", -60 * deg, -40 * deg, 0},
+		{"steep", 170 * deg, 80 * deg, 0},
+		{"right side up", 45 * deg, 0, 30 * deg},
+		{"left side up", -100 * deg, 0, -50 * deg},
+	} {
+		var x, y, z [3]float64
+		if tt.roll != 0 {
+			x, y, z = rolled(tt.roll)
+		} else {
+			x, y, z = pitched(tt.pitch)
+		}
+		m, a := sensor(field(tt.heading), x, y, z)
+		h, p, r := TiltCompensated(m[0], m[1], m[2], a[0], a[1], a[2])
+		if math.Abs(Wrap(h-tt.heading)) > 1e-6 || math.Abs(p-tt.pitch) > 1e-6 || math.Abs(r-tt.roll) > 1e-6 {
+			t.Errorf("%s: heading %.2f pitch %.2f roll %.2f, want %.2f %.2f %.2f", tt.name,
+				h/deg, p/deg, r/deg, tt.heading/deg, tt.pitch/deg, tt.roll/deg)
+		}
+
+		// without compensation the tilt pulls the heading off
+		if tt.pitch != 0 || tt.roll != 0 {
+			if raw := Heading(m[0], m[1], m[2]); math.Abs(Wrap(raw-tt.heading)) < 1e-3 {
+				t.Errorf("%s: tilt did not matter, the test proves nothing", tt.name)
+			}
+		}
+	}
+}
+
+func TestTiltCompensatedWithoutGravity(t *testing.T) {
+	// in free fall, or with a dead accelerometer, it is a plain compass
+	h, p, r := TiltCompensated(0, 400, -300, 0, 0, 0)
+	if h != Heading(0, 400, -300) || p != 0 || r != 0 {
+		t.Errorf("got %v %v %v, want %v 0 0", h, p, r, Heading(0, 400, -300))
+	}
+	h, p, r = TiltCompensated(0, 0, 0, 0, 0, 0)
+	if h != 0 || p != 0 || r != 0 {
+		t.Errorf("nothing at all: got %v %v %v", h, p, r)
+	}
+}
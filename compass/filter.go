@@ -0,0 +1,98 @@
+package compass
+
+import (
+	"math"
+	"time"
+)
+
+// Wrap returns the angle a, in radians, brought into [-π, π).
+func Wrap(a float64) float64 {
+	a = math.Mod(a+math.Pi, 2*math.Pi)
+	if a < 0 {
+		a += 2 * math.Pi
+	}
+	return a - math.Pi
+}
+
+// AlphaFor returns the Filter alpha that gives a response time of tau when
+// it is updated every dt. A zero tau turns smoothing off.
+func AlphaFor(tau, dt time.Duration) float64 {
+	if tau <= 0 {
+		return 1
+	}
+	return 1 - math.Exp(-float64(dt)/float64(tau))
+}
+
+// Filter smooths a stream of headings with an exponential moving average.
+// It always moves the short way around the circle, so going past ±π does
+// not swing the average through zero.
+type Filter struct {
+	// Alpha is how much of each new reading gets through, from 0 (the
+	// heading never moves) to 1 (no smoothing at all).
+	Alpha float64
+
+	value  float64
+	primed bool
+}
+
+// Update feeds a new heading, in radians, and returns the smoothed one.
+func (f *Filter) Update(heading float64) float64 {
+	if !f.primed {
+		f.value = Wrap(heading)
+		f.primed = true
+		return f.value
+	}
+	f.value = Wrap(f.value + f.Alpha*Wrap(heading-f.value))
+	return f.value
+}
+
+// Value returns the current smoothed heading.
+func (f *Filter) Value() float64 {
+	return f.value
+}
+
+// Reset makes the next Update start from scratch.
+func (f *Filter) Reset() {
+	f.primed = false
+}
+
+// Hysteresis turns a position on a ring of Steps cells into the index of a
+// cell, but only leaves the current cell once the position is more than
+// Margin cells past its border. It stops a heading sitting on the boundary
+// between two LEDs from flickering between both.
+type Hysteresis struct {
+	Steps  int
+	Margin float64
+
+	index  int
+	primed bool
+}
+
+// Update feeds a new position, in cells, and returns the cell it falls in,
+// between 0 and Steps-1.
+func (h *Hysteresis) Update(pos float64) int {
+	steps := float64(h.Steps)
+	pos = math.Mod(pos, steps)
+	if pos < 0 {
+		pos += steps
+	}
+	if h.primed {
+		// distance from the middle of the current cell, the short way round
+		d := math.Mod(pos-(float64(h.index)+0.5)+steps/2, steps)
+		if d < 0 {
+			d += steps
+		}
+		d -= steps / 2
+		if math.Abs(d) <= 0.5+h.Margin {
+			return h.index
+		}
+	}
+	h.index = int(pos) % h.Steps
+	h.primed = true
+	return h.index
+}
+
+// Reset makes the next Update start from scratch.
+func (h *Hysteresis) Reset() {
+	h.primed = false
+}
@@ -0,0 +1,154 @@
+package compass
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestWrap(t *testing.T) {
+	for _, tt := range []struct{ in, want float64 }{
+		{0, 0},
+		{1, 1},
+		{math.Pi, -math.Pi},
+		{-math.Pi, -math.Pi},
+		{3 * math.Pi / 2, -math.Pi / 2},
+		{-3 * math.Pi / 2, math.Pi / 2},
+		{5 * math.Pi, -math.Pi},
+		{-4*math.Pi + 0.5, 0.5},
+	} {
+		if got := Wrap(tt.in); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("Wrap(%v) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+// noise returns a repeatable pseudo random value in [-amp, amp].
+func noise(i int, amp float64) float64 {
+	x := uint32(i)*2654435761 + 12345
+	x ^= x >> 13
+	x *= 0x5bd1e995
+	x ^= x >> 15
+	return amp * (2*float64(x)/math.MaxUint32 - 1)
+}
+
+func TestFilterStability(t *testing.T) {
+	const (
+		heading = 1.0
+		amp     = 0.15
+	)
+	alpha := AlphaFor(100*time.Millisecond, 50*time.Millisecond)
+	f := Filter{Alpha: alpha}
+	f.Update(heading)
+
+	var raw, filtered float64
+	const n = 2000
+	for i := 0; i < n; i++ {
+		r := heading + noise(i, amp)
+		got := f.Update(r)
+		raw += (r - heading) * (r - heading)
+		filtered += (got - heading) * (got - heading)
+	}
+	raw, filtered = math.Sqrt(raw/n), math.Sqrt(filtered/n)
+	// white noise through an exponential average keeps
+	// sqrt(alpha/(2-alpha)) of its spread
+	want := raw * math.Sqrt(alpha/(2-alpha))
+	if filtered > want*1.2 {
+		t.Errorf("filtered heading spreads %.4f, readings %.4f, want about %.4f", filtered, raw, want)
+	}
+}
+
+func TestFilterLatency(t *testing.T) {
+	const dt = 50 * time.Millisecond
+	for _, tau := range []time.Duration{0, 50 * time.Millisecond, 100 * time.Millisecond, 200 * time.Millisecond} {
+		f := Filter{Alpha: AlphaFor(tau, dt)}
+		f.Update(0)
+
+		// a step of one radian, how long until 90% of it is through
+		var took time.Duration
+		for f.Update(1) < 0.9 {
+			took += dt
+			if took > time.Second {
+				t.Fatalf("tau %v: still not there after %v", tau, took)
+			}
+		}
+		// an exponential gets to 90% after tau·ln(10)
+		want := time.Duration(float64(tau) * math.Ln10)
+		if took < want-dt || took > want+dt {
+			t.Errorf("tau %v: 90%% after %v, want about %v", tau, took, want)
+		}
+	}
+}
+
+func TestFilterWrapsAroundPi(t *testing.T) {
+	f := Filter{Alpha: 0.5}
+	f.Update(math.Pi - 0.1)
+
+	// crossing ±π goes the short way, never through zero
+	for i := 0; i < 20; i++ {
+		got := f.Update(-math.Pi + 0.1)
+		if math.Abs(got) < math.Pi-0.11 {
+			t.Fatalf("step %d: heading %v swung away from ±π", i, got)
+		}
+	}
+	if got := f.Value(); math.Abs(got-(-math.Pi+0.1)) > 0.01 {
+		t.Errorf("settled at %v, want %v", got, -math.Pi+0.1)
+	}
+
+	// noise around ±π averages to ±π, not to zero
+	f.Reset()
+	for i := 0; i < 200; i++ {
+		f.Update(Wrap(math.Pi + noise(i, 0.2)))
+	}
+	if got := math.Abs(f.Value()); got < math.Pi-0.1 {
+		t.Errorf("noise around ±π settled at %v", f.Value())
+	}
+}
+
+func TestHysteresis(t *testing.T) {
+	h := Hysteresis{Steps: 88, Margin: 0.3}
+	for _, tt := range []struct {
+		pos  float64
+		want int
+	}{
+		{10.5, 10},
+		// past the border but within the margin
+		{11.2, 10},
+		{11.29, 10},
+		// beyond the margin
+		{11.31, 11},
+		// and back, the margin works both ways
+		{10.8, 11},
+		{10.71, 11},
+		{10.6, 10},
+		// a jump is taken straight away
+		{40.5, 40},
+		// round the end of the ring
+		{87.5, 87},
+		{0.2, 87},
+		{88.25, 87},
+		{0.4, 0},
+		{-0.2, 0},
+		{-0.4, 87},
+	} {
+		if got := h.Update(tt.pos); got != tt.want {
+			t.Errorf("Update(%v) = %d, want %d", tt.pos, got, tt.want)
+		}
+	}
+
+	h.Reset()
+	if got := h.Update(11.2); got != 11 {
+		t.Errorf("after Reset Update(11.2) = %d, want 11", got)
+	}
+}
+
+func TestHysteresisHoldsOnNoise(t *testing.T) {
+	h := Hysteresis{Steps: 88, Margin: 0.3}
+	h.Update(20.5)
+	// a position sitting on the border between 20 and 21
+	for i := 0; i < 200; i++ {
+		if got := h.Update(21 + noise(i, 0.25)); got != 20 {
+			t.Fatalf("step %d: moved to %d", i, got)
+		}
+	}
+}
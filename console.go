@@ -0,0 +1,198 @@
+package main
+
+import (
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/conejoninja/vision/command"
+	"github.com/conejoninja/vision/logging"
+	"github.com/conejoninja/vision/shell"
+)
+
+// console is the shell on the serial port, its In and Out are set by the
+// board. Lines it does not know are tried as remote commands, so anything
+// that works over MQTT works there too.
+var console = shell.Shell{
+	Prompt:   "> ",
+	Echo:     true,
+	Fallback: runLine,
+}
+
+func init() {
+	console.Commands = []shell.Command{
+		{Name: "status", Help: "game, mode and link", Run: shellStatus},
+		{Name: "heading", Help: "where the headset looks", Run: shellHeading},
+		{Name: "calib", Args: "[DONE|CANCEL]", Help: "calibrate the compass", Run: alias("CALIBRATE")},
+		{Name: "center", Help: "look straight ahead", Run: alias("CENTER")},
+		{Name: "game", Args: "<name>", Help: "switch game", Run: alias("GAME")},
+		{Name: "leds", Args: "TEST", Help: "cycle every LED through the colours", Run: shellLEDs},
+		{Name: "mqtt", Args: "[STATUS|ON|OFF]", Help: "broker link", Run: shellMQTT},
+		{Name: "set", Args: "<key> <value>", Help: "change a network setting", Run: provisioned("SET")},
+		{Name: "clear", Args: "<key>", Help: "forget a network setting", Run: provisioned("CLEAR")},
+		{Name: "show", Help: "list the network settings", Run: provisioned("SHOW")},
+		{Name: "save", Help: "store the settings and reconnect", Run: provisioned("SAVE")},
+		{Name: "log", Args: "[<level> [tag]|SINK <sink>]", Help: "show the log or change it", Run: shellLog},
+		{Name: "reboot", Help: "restart the headset", Run: shellReboot},
+	}
+}
+
+// modeNames names the modes of the main loop.
+var modeNames = [...]string{
+	IDLE:        "IDLE",
+	CENTERING:   "CENTERING",
+	CALIBRATING: "CALIBRATING",
+	STATS:       "STATS",
+	MENU:        "MENU",
+}
+
+// pollConsole runs whatever was typed on the console, without waiting for
+// more.
+func pollConsole() {
+	console.Poll()
+}
+
+// runLine runs a remote command typed on the console.
+func runLine(w io.Writer, line string) error {
+	c, err := command.Parse([]byte(line))
+	if err == command.ErrUnknown {
+		return shell.ErrUnknown
+	}
+	if err != nil {
+		return shell.ErrArgs
+	}
+	if err := runCommand(c); err != nil {
+		return err
+	}
+	shell.Print(w, "OK")
+	return nil
+}
+
+// alias runs the remote command word with the arguments typed.
+func alias(word string) shell.Handler {
+	return func(w io.Writer, args string) error {
+		return runLine(w, word+" "+args)
+	}
+}
+
+// provisioned hands the line to the provisioning commands.
+func provisioned(op string) shell.Handler {
+	return func(w io.Writer, args string) error {
+		for _, reply := range provisionLine(op + " " + args) {
+			shell.Print(w, reply)
+		}
+		return nil
+	}
+}
+
+func shellStatus(w io.Writer, args string) error {
+	if args != "" {
+		return shell.ErrArgs
+	}
+	race := "OFF"
+	if racing {
+		race = strconv.Itoa(len(mazeRace.Peers)+1) + " IN"
+	}
+	s := &clock.Stats
+	shell.Print(w, "DEVICE", DeviceID, "FIRMWARE", FirmwareVersion)
+	shell.Print(w, "GAME", games[game].Name(), "MODE", modeNames[mode], "RACE", race)
+	shell.Print(w, "WIFI", onOff(useWifi), "MQTT", onOff(useMQTT), "LINK", network.State().String())
+	shell.Print(w, "FRAME AVG", ms(s.Average()), "MAX", ms(s.Max), "OVERRUNS", strconv.Itoa(int(s.Overruns)))
+	shell.Print(w, "BRIGHTNESS", strconv.Itoa(brightness))
+	return nil
+}
+
+func shellHeading(w io.Writer, args string) error {
+	if args != "" {
+		return shell.ErrArgs
+	}
+	shell.Print(w, "HEADING", degrees(headingRads), "RAW", degrees(rawHeadingRads), "OFFSET", degrees(offsetHeadingRads))
+	shell.Print(w, "PITCH", degrees(pitch), "ROLL", degrees(roll))
+	shell.Print(w, "LED", strconv.Itoa(ledIndex), "OFFSET", strconv.Itoa(offsetHeading))
+	return nil
+}
+
+func degrees(rads float64) string {
+	return strconv.FormatFloat(rads*180/math.Pi, 'f', 1, 64)
+}
+
+// ledTest is how long the LED test keeps running.
+var ledTest time.Duration
+
+// ledTestStep is how long each colour of the LED test lasts.
+const ledTestStep = 500 * time.Millisecond
+
+var ledTestColors = [...]int{RED, GREEN, BLUE, WHITE}
+
+func shellLEDs(w io.Writer, args string) error {
+	if !strings.EqualFold(args, "TEST") {
+		return shell.ErrArgs
+	}
+	ledTest = ledTestStep * time.Duration(len(ledTestColors))
+	shell.Print(w, "OK")
+	return nil
+}
+
+// testLEDs paints the LED test over the game, dt after the previous frame.
+func testLEDs(dt time.Duration) {
+	if ledTest <= 0 {
+		return
+	}
+	step := len(ledTestColors) - 1 - int((ledTest-1)/ledTestStep)
+	for i := range leds {
+		leds[i] = colors[ledTestColors[step]]
+	}
+	ledTest -= dt
+}
+
+func shellMQTT(w io.Writer, args string) error {
+	switch strings.ToUpper(args) {
+	case "", "STATUS":
+	case "ON":
+		setMQTT(true)
+		saveSettings()
+	case "OFF":
+		setMQTT(false)
+		saveSettings()
+	default:
+		return shell.ErrArgs
+	}
+	broker := brokerAddress()
+	if broker == "" {
+		broker = "-"
+	}
+	shell.Print(w, "LINK", network.State().String(), "BROKER", broker)
+	shell.Print(w, "ATTEMPTS", strconv.Itoa(int(network.Attempts)), "DROPS", strconv.Itoa(int(network.Drops)))
+	if err := network.Err(); err != nil {
+		shell.Print(w, "LAST ERROR", err.Error())
+	}
+	o := &outgoing.Stats
+	shell.Print(w, "SENT", strconv.Itoa(int(o.Sent)), "FAILED", strconv.Itoa(int(o.Failed)),
+		"DROPPED", strconv.Itoa(int(o.Dropped)), "SKIPPED", strconv.Itoa(int(o.Skipped)),
+		"QUEUED", strconv.Itoa(outgoing.Queued()))
+	return nil
+}
+
+func shellLog(w io.Writer, args string) error {
+	if args != "" && !strings.EqualFold(args, "SHOW") {
+		return runLine(w, "LOG "+args)
+	}
+	shell.Print(w, "LEVEL", logger.CurrentLevel().String(), "SINK", logSinkNames[logSink])
+	var e logging.Entry
+	var line []byte
+	for seq := uint32(0); logger.Read(seq, &e); seq = e.Seq + 1 {
+		line = e.Append(line[:0])
+		shell.Print(w, string(line))
+	}
+	return nil
+}
+
+func shellReboot(w io.Writer, args string) error {
+	if args != "" {
+		return shell.ErrArgs
+	}
+	shell.Print(w, "REBOOTING")
+	return reboot()
+}
@@ -0,0 +1,18 @@
+//go:build !tinygo
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// The console reads nothing on a host, stdin belongs to the simulated
+// buttons and stdout to the screen, the log goes to stderr.
+func init() {
+	serialLog = os.Stderr
+}
+
+func reboot() error {
+	return errors.New("no reboot in the simulator")
+}
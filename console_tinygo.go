@@ -0,0 +1,38 @@
+//go:build tinygo
+
+package main
+
+import (
+	"machine"
+	"time"
+)
+
+func init() {
+	console.In = serialReader{}
+	console.Out = machine.Serial
+	serialLog = machine.Serial
+}
+
+// serialReader reads what already arrived on the USB serial port, it never
+// waits for more.
+type serialReader struct{}
+
+func (serialReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) && machine.Serial.Buffered() > 0 {
+		b, err := machine.Serial.ReadByte()
+		if err != nil {
+			return n, err
+		}
+		p[n] = b
+		n++
+	}
+	return n, nil
+}
+
+// reboot gives the reply a moment to leave before resetting.
+func reboot() error {
+	time.Sleep(100 * time.Millisecond)
+	machine.CPUReset()
+	return nil
+}
@@ -0,0 +1,181 @@
+package controls
+
+import (
+	"time"
+
+	"github.com/conejoninja/vision/hal"
+)
+
+// MaxButtons is how many buttons Buttons can follow.
+const MaxButtons = 32
+
+// Kind is what happened to a button.
+type Kind uint8
+
+const (
+	// Press is sent when a button goes down and Release when it comes back
+	// up.
+	Press Kind = iota + 1
+	Release
+	// LongPress is sent once when a button has been held for LongPress.
+	LongPress
+	// DoubleClick is sent, after the Press, when a button goes down again
+	// within DoubleClick of its last release.
+	DoubleClick
+	// Repeat is sent every RepeatRate once a button has been held for
+	// RepeatDelay, like a key on a keyboard.
+	Repeat
+	// Combo is sent when every button of one of the Combos is held at once.
+	Combo
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Press:
+		return "press"
+	case Release:
+		return "release"
+	case LongPress:
+		return "long"
+	case DoubleClick:
+		return "double"
+	case Repeat:
+		return "repeat"
+	case Combo:
+		return "combo"
+	}
+	return "?"
+}
+
+// Event is something that happened to a button, or to a combo of them.
+type Event struct {
+	Kind Kind
+	// Button is the index of the button, -1 for combos.
+	Button int
+	// Mask has a bit set for every button involved.
+	Mask uint32
+}
+
+// Mask returns the bit mask for the given buttons, to build Combos.
+func Mask(buttons ...int) uint32 {
+	var m uint32
+	for _, b := range buttons {
+		m |= 1 << b
+	}
+	return m
+}
+
+type buttonState struct {
+	raw      bool
+	rawFor   time.Duration
+	down     bool
+	downFor  time.Duration
+	upFor    time.Duration
+	recent   bool
+	double   bool
+	long     bool
+	repeatAt time.Duration
+}
+
+// Buttons turns the raw state of the buttons into events. Update has to be
+// called regularly with the time since the previous call.
+type Buttons struct {
+	Buttons hal.Buttons
+
+	// Debounce is how long a button has to stay in a new state before it
+	// counts.
+	Debounce time.Duration
+	// LongPress and DoubleClick are the thresholds for those events, zero
+	// turns them off.
+	LongPress, DoubleClick time.Duration
+	// RepeatDelay is how long a button is held before it starts repeating
+	// every RepeatRate, zero turns repeat off.
+	RepeatDelay, RepeatRate time.Duration
+	// Combos are the sets of buttons, see Mask, that send a Combo event when
+	// held together.
+	Combos []uint32
+
+	state  [MaxButtons]buttonState
+	held   uint32
+	events []Event
+}
+
+// Update reads the buttons, dt after the previous call, and returns what
+// happened in between. The slice is only valid until the next call.
+func (b *Buttons) Update(dt time.Duration) []Event {
+	b.events = b.events[:0]
+	n := b.Buttons.Len()
+	if n > MaxButtons {
+		n = MaxButtons
+	}
+	before := b.held
+	for i := 0; i < n; i++ {
+		b.update(i, b.Buttons.Pressed(i), dt)
+	}
+	for _, combo := range b.Combos {
+		if b.held&combo == combo && before&combo != combo {
+			b.emit(Combo, -1, combo)
+		}
+	}
+	return b.events
+}
+
+func (b *Buttons) update(i int, raw bool, dt time.Duration) {
+	s := &b.state[i]
+	if raw != s.raw {
+		// it changed some time since the last read, call it halfway
+		s.raw = raw
+		s.rawFor = dt / 2
+	} else {
+		s.rawFor += dt
+	}
+
+	if s.raw != s.down && s.rawFor >= b.Debounce {
+		s.down = s.raw
+		if s.down {
+			b.held |= 1 << i
+			b.emit(Press, i, 1<<i)
+			s.double = b.DoubleClick > 0 && s.recent
+			if s.double {
+				b.emit(DoubleClick, i, 1<<i)
+			}
+			s.recent = false
+			s.downFor = 0
+			s.long = false
+			s.repeatAt = b.RepeatDelay
+		} else {
+			b.held &^= 1 << i
+			b.emit(Release, i, 1<<i)
+			s.upFor = 0
+			// a third click starts a new double click
+			s.recent = !s.double
+		}
+		return
+	}
+
+	if !s.down {
+		if s.recent {
+			s.upFor += dt
+			s.recent = s.upFor < b.DoubleClick
+		}
+		return
+	}
+	s.downFor += dt
+	if b.LongPress > 0 && !s.long && s.downFor >= b.LongPress {
+		s.long = true
+		b.emit(LongPress, i, 1<<i)
+	}
+	if b.RepeatDelay > 0 && b.RepeatRate > 0 && s.downFor >= s.repeatAt {
+		s.repeatAt += b.RepeatRate
+		b.emit(Repeat, i, 1<<i)
+	}
+}
+
+func (b *Buttons) emit(k Kind, button int, mask uint32) {
+	b.events = append(b.events, Event{Kind: k, Button: button, Mask: mask})
+}
+
+// Held reports whether button i is down, after debouncing.
+func (b *Buttons) Held(i int) bool {
+	return b.held&(1<<i) != 0
+}
@@ -0,0 +1,143 @@
+package controls
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/conejoninja/vision/hal"
+)
+
+const tick = 10 * time.Millisecond
+
+// fakeButtons returns two debounced buttons with every event turned on.
+func fakeButtons() (*Buttons, hal.FakeButtons) {
+	raw := make(hal.FakeButtons, 2)
+	return &Buttons{
+		Buttons:     raw,
+		Debounce:    20 * time.Millisecond,
+		LongPress:   500 * time.Millisecond,
+		DoubleClick: 200 * time.Millisecond,
+		RepeatDelay: 300 * time.Millisecond,
+		RepeatRate:  100 * time.Millisecond,
+		Combos:      []uint32{Mask(0, 1)},
+	}, raw
+}
+
+// run updates b for d, one tick at a time, and returns every event.
+func run(b *Buttons, d time.Duration) []Event {
+	var all []Event
+	for ; d > 0; d -= tick {
+		all = append(all, b.Update(tick)...)
+	}
+	return all
+}
+
+func press(i int) Event   { return Event{Press, i, 1 << i} }
+func release(i int) Event { return Event{Release, i, 1 << i} }
+
+func check(t *testing.T, what string, got []Event, want ...Event) {
+	t.Helper()
+	if !reflect.DeepEqual(got, want) && (len(got) != 0 || len(want) != 0) {
+		t.Errorf("%s: got %v, want %v", what, got, want)
+	}
+}
+
+func TestDebounce(t *testing.T) {
+	b, raw := fakeButtons()
+
+	// bouncing contacts settle before anything is sent
+	for i := 0; i < 6; i++ {
+		raw[0] = i%2 == 0
+		check(t, "bouncing", b.Update(tick))
+	}
+	raw[0] = true
+	check(t, "settled down", run(b, 30*time.Millisecond), press(0))
+	if !b.Held(0) {
+		t.Error("not held after the press")
+	}
+
+	// a glitch shorter than the debounce is ignored
+	raw[0] = false
+	b.Update(tick)
+	raw[0] = true
+	check(t, "glitch", run(b, 50*time.Millisecond))
+
+	raw[0] = false
+	check(t, "settled up", run(b, 30*time.Millisecond), release(0))
+	if b.Held(0) {
+		t.Error("still held after the release")
+	}
+}
+
+func TestLongPressAndRepeat(t *testing.T) {
+	b, raw := fakeButtons()
+	raw[0] = true
+	check(t, "down", run(b, 30*time.Millisecond), press(0))
+
+	// held for 30ms so far: repeats at 300, 400 and 500, long at 500
+	got := run(b, 500*time.Millisecond)
+	check(t, "held", got,
+		Event{Repeat, 0, 1},
+		Event{Repeat, 0, 1},
+		Event{LongPress, 0, 1},
+		Event{Repeat, 0, 1},
+	)
+
+	// the long press is only sent once
+	for _, e := range run(b, time.Second) {
+		if e.Kind != Repeat {
+			t.Errorf("held longer: got %v", e)
+		}
+	}
+}
+
+func TestShortPressSendsNoLongPress(t *testing.T) {
+	b, raw := fakeButtons()
+	b.RepeatDelay = 0
+	raw[0] = true
+	run(b, 200*time.Millisecond)
+	raw[0] = false
+	check(t, "released early", run(b, time.Second), release(0))
+}
+
+func TestDoubleClick(t *testing.T) {
+	b, raw := fakeButtons()
+	click := func() []Event {
+		raw[0] = true
+		e := run(b, 50*time.Millisecond)
+		raw[0] = false
+		return append(e, run(b, 50*time.Millisecond)...)
+	}
+
+	check(t, "first click", click(), press(0), release(0))
+	check(t, "second click", click(), press(0), Event{DoubleClick, 0, 1}, release(0))
+	// a third click starts over
+	check(t, "third click", click(), press(0), release(0))
+	check(t, "fourth click", click(), press(0), Event{DoubleClick, 0, 1}, release(0))
+
+	// too slow for a double click
+	run(b, 300*time.Millisecond)
+	check(t, "late click", click(), press(0), release(0))
+}
+
+func TestCombo(t *testing.T) {
+	b, raw := fakeButtons()
+	raw[0] = true
+	check(t, "first button", run(b, 30*time.Millisecond), press(0))
+	raw[1] = true
+	check(t, "both", run(b, 30*time.Millisecond), press(1), Event{Combo, -1, Mask(0, 1)})
+
+	// held on, the combo is not sent again
+	for _, e := range run(b, time.Second) {
+		if e.Kind == Combo {
+			t.Error("combo sent again while held")
+		}
+	}
+
+	// letting go of one button and pressing it again sends it again
+	raw[1] = false
+	run(b, 300*time.Millisecond)
+	raw[1] = true
+	check(t, "again", run(b, 30*time.Millisecond), press(1), Event{Combo, -1, Mask(0, 1)})
+}
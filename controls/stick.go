@@ -0,0 +1,76 @@
+// Package controls turns the raw buttons and joystick into something games can
+// use.
+package controls
+
+import (
+	"math"
+
+	"github.com/conejoninja/vision/hal"
+)
+
+// calibrationSamples is how many readings Calibrate averages.
+const calibrationSamples = 16
+
+// Stick reads an analog joystick as a direction with a length between 0 and
+// 1. Small movements around the centre are ignored.
+type Stick struct {
+	Joystick hal.Joystick
+
+	// CenterX and CenterY are the readings at rest, see Calibrate.
+	CenterX, CenterY uint16
+	// Deadzone is the part of the travel ignored around the centre, from 0
+	// to 1. It is radial, so diagonals are not harder to reach.
+	Deadzone float64
+	// InvertX and InvertY flip the axes, the stick on the headset reads
+	// low to the right and forward.
+	InvertX, InvertY bool
+}
+
+// Calibrate takes the current position as the centre. The stick has to be
+// left alone while it runs.
+func (s *Stick) Calibrate() {
+	var sx, sy uint32
+	for i := 0; i < calibrationSamples; i++ {
+		x, y := s.Joystick.Get()
+		sx += uint32(x)
+		sy += uint32(y)
+	}
+	s.CenterX = uint16(sx / calibrationSamples)
+	s.CenterY = uint16(sy / calibrationSamples)
+}
+
+// Read returns where the stick is pointing: x grows to the right and y
+// forward, and the length of (x, y) goes from 0 at rest to 1 fully pushed.
+func (s *Stick) Read() (x, y float64) {
+	rx, ry := s.Joystick.Get()
+	x = axis(rx, s.CenterX, s.InvertX)
+	y = axis(ry, s.CenterY, s.InvertY)
+
+	m := math.Sqrt(x*x + y*y)
+	if m <= s.Deadzone || m == 0 {
+		return 0, 0
+	}
+	// rescale what is left after the deadzone back to 0..1
+	scaled := (m - s.Deadzone) / (1 - s.Deadzone)
+	if scaled > 1 {
+		scaled = 1
+	}
+	return x / m * scaled, y / m * scaled
+}
+
+// axis maps a raw reading to -1..1, each side of the centre scaled on its
+// own since the centre is rarely in the middle of the range.
+func axis(raw, center uint16, invert bool) float64 {
+	var v float64
+	if raw >= center {
+		if span := math.MaxUint16 - float64(center); span > 0 {
+			v = (float64(raw) - float64(center)) / span
+		}
+	} else if center > 0 {
+		v = (float64(raw) - float64(center)) / float64(center)
+	}
+	if invert {
+		v = -v
+	}
+	return v
+}
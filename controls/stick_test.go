@@ -0,0 +1,87 @@
+package controls
+
+import (
+	"math"
+	"testing"
+
+	"github.com/conejoninja/vision/hal"
+)
+
+// fakeStick returns a stick over two fake ADCs resting at (cx, cy),
+// already calibrated.
+func fakeStick(cx, cy uint16) (*Stick, *hal.FakeADC, *hal.FakeADC) {
+	x, y := hal.FakeADC(cx), hal.FakeADC(cy)
+	s := &Stick{Joystick: hal.AnalogJoystick{X: &x, Y: &y}, Deadzone: 0.1}
+	s.Calibrate()
+	return s, &x, &y
+}
+
+func near(a, b float64) bool { return math.Abs(a-b) < 1e-3 }
+
+func TestStickDeadzone(t *testing.T) {
+	s, x, y := fakeStick(32768, 32768)
+	for _, tt := range []struct {
+		name         string
+		rx, ry       uint16
+		wantX, wantY float64
+	}{
+		{"at rest", 32768, 32768, 0, 0},
+		{"inside the deadzone", 32768 + 3000, 32768, 0, 0},
+		{"inside the deadzone on a diagonal", 32768 + 2200, 32768 + 2200, 0, 0},
+		{"halfway right", 32768 + 32767/2, 32768, (0.5 - 0.1) / 0.9, 0},
+		{"full right", math.MaxUint16, 32768, 1, 0},
+		{"full left", 0, 32768, -1, 0},
+		{"full forward", 32768, math.MaxUint16, 0, 1},
+		// the corners are no longer than the sides
+		{"full diagonal", math.MaxUint16, math.MaxUint16, math.Sqrt2 / 2, math.Sqrt2 / 2},
+	} {
+		*x, *y = hal.FakeADC(tt.rx), hal.FakeADC(tt.ry)
+		gx, gy := s.Read()
+		if !near(gx, tt.wantX) || !near(gy, tt.wantY) {
+			t.Errorf("%s: got %.3f,%.3f, want %.3f,%.3f", tt.name, gx, gy, tt.wantX, tt.wantY)
+		}
+	}
+}
+
+func TestStickNoDeadzone(t *testing.T) {
+	s, x, _ := fakeStick(32768, 32768)
+	s.Deadzone = 0
+	*x = 32768 + 3277
+	if gx, _ := s.Read(); !near(gx, 0.1) {
+		t.Errorf("got %.3f, want 0.1", gx)
+	}
+}
+
+func TestStickInvert(t *testing.T) {
+	s, x, y := fakeStick(32768, 32768)
+	s.InvertX, s.InvertY = true, true
+	*x = math.MaxUint16
+	if gx, gy := s.Read(); !near(gx, -1) || gy != 0 {
+		t.Errorf("right: got %.3f,%.3f, want -1,0", gx, gy)
+	}
+	*x, *y = 32768, 0
+	if gx, gy := s.Read(); gx != 0 || !near(gy, 1) {
+		t.Errorf("back: got %.3f,%.3f, want 0,1", gx, gy)
+	}
+}
+
+func TestStickOffCentre(t *testing.T) {
+	// a stick resting low still reaches 1 on both sides
+	s, x, _ := fakeStick(20000, 32768)
+	if s.CenterX != 20000 {
+		t.Fatalf("calibrated to %d, want 20000", s.CenterX)
+	}
+	for _, tt := range []struct {
+		raw  uint16
+		want float64
+	}{
+		{0, -1},
+		{10000, -(0.5 - 0.1) / 0.9},
+		{math.MaxUint16, 1},
+	} {
+		*x = hal.FakeADC(tt.raw)
+		if gx, _ := s.Read(); !near(gx, tt.want) {
+			t.Errorf("raw %d: got %.3f, want %.3f", tt.raw, gx, tt.want)
+		}
+	}
+}
@@ -1,18 +1,50 @@
 package main
 
+import (
+	"github.com/conejoninja/vision/topic"
+)
+
 const (
 	MQTTClientID = "GopherVision3000"
 
-	discoveryTopic          = "vision"
-	orientationTopic        = "vision/orientation"
-	ledsTopic               = "vision/leds"
-	circlesTopic            = "vision/circles"
-	circlesArcTopic         = "vision/circleArc"
-	circlesOrientationTopic = "vision/circleOrientation"
-	circlesRadiusTopic      = "vision/circleRadius"
-	mazeTopic               = "vision/maze"
+	discoveryTopic = topic.Discovery
+)
+
+// Topics of this headset, under its DeviceID. setTopics builds them, see
+// package topic for the layout.
+var (
+	orientationTopic        string
+	ledsTopic               string
+	pitchTopic              string
+	rollTopic               string
+	circlesTopic            string
+	circlesArcTopic         string
+	circlesOrientationTopic string
+	circlesRadiusTopic      string
+	mazeTopic               string
+	mazeSeedTopic           string
+	mazeTimeTopic           string
+	statsTopic              string
+
+	// commandTopic takes the remote commands for this headset, see package
+	// command for what they look like.
+	commandTopic string
+	// announceTopic keeps what this headset is and can do, statusTopic
+	// whether it is online. Both are retained.
+	announceTopic string
+	statusTopic   string
+	// telemetryTopic carries the telemetry frame, see package telemetry.
+	telemetryTopic string
+	// raceTopic is where this headset talks to the others in a race,
+	// racersTopic where it listens to all of them, see package race.
+	raceTopic   string
+	racersTopic = topic.Any(topic.Race)
+	// logTopic carries the log when it goes to MQTT, see logs.go.
+	logTopic string
 )
 
+// The network the firmware is built with, provisioning over the serial
+// console overrides them without rebuilding, see provisioning.go.
 var (
 	WifiSSID     = ""
 	WifiPassword = ""
@@ -23,3 +55,30 @@ var (
 	MQTTUser     = ""
 	MQTTPassword = ""
 )
+
+func init() {
+	setTopics(DeviceID)
+}
+
+// setTopics puts every topic under device.
+func setTopics(device string) {
+	orientationTopic = topic.For(device, topic.Orientation)
+	ledsTopic = topic.For(device, topic.LEDs)
+	pitchTopic = topic.For(device, topic.Pitch)
+	rollTopic = topic.For(device, topic.Roll)
+	circlesTopic = topic.For(device, topic.Circles)
+	circlesArcTopic = topic.For(device, topic.CircleArc)
+	circlesOrientationTopic = topic.For(device, topic.CircleOrientation)
+	circlesRadiusTopic = topic.For(device, topic.CircleRadius)
+	mazeTopic = topic.For(device, topic.Maze)
+	mazeSeedTopic = topic.For(device, topic.MazeSeed)
+	mazeTimeTopic = topic.For(device, topic.MazeTime)
+	statsTopic = topic.For(device, topic.Stats)
+	commandTopic = topic.For(device, topic.Command)
+	announceTopic = topic.For(device, topic.Announce)
+	statusTopic = topic.For(device, topic.Status)
+	telemetryTopic = topic.For(device, topic.Telemetry)
+	raceTopic = topic.For(device, topic.Race)
+	logTopic = topic.For(device, topic.Log)
+	outgoing.Rules = outboxRules()
+}
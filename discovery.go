@@ -0,0 +1,113 @@
+package main
+
+import (
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/conejoninja/vision/link"
+	"github.com/conejoninja/vision/topic"
+)
+
+// capabilities the announcement lists, so dashboards know what to offer.
+var capabilities = []string{"commands", "telemetry-v1", "race-v1", "orientation", "leds", "maze-seed", "maze-time", "stats", "calibration", "brightness", "colors"}
+
+// announcedNames are the topics a dashboard may want, they are announced
+// with their full name.
+var announcedNames = []string{
+	topic.Command, topic.Status, topic.Telemetry,
+	topic.Orientation, topic.LEDs, topic.Pitch, topic.Roll,
+	topic.Circles, topic.CircleArc, topic.CircleOrientation, topic.CircleRadius,
+	topic.Maze, topic.MazeSeed, topic.MazeTime, topic.Race, topic.Stats,
+	topic.Log,
+}
+
+// announcement is what gets published, retained, on every new MQTT session:
+// the headset's description on announceTopic and then "online" on
+// statusTopic, which the will turns into "offline" when it goes away.
+func announcement() []link.Message {
+	return []link.Message{
+		{Topic: announceTopic, Payload: discoveryJSON(), Retain: true},
+		{Topic: statusTopic, Payload: []byte("online"), Retain: true},
+	}
+}
+
+// publishDiscovery announces the headset again, when a dashboard asks on
+// discoveryTopic.
+func publishDiscovery() {
+	for _, msg := range announcement() {
+		if err := network.Retain(msg.Topic, msg.Payload); err != nil {
+			return
+		}
+	}
+}
+
+// discoveryJSON describes the headset. It is written by hand, encoding/json
+// is too big for the board.
+func discoveryJSON() []byte {
+	b := []byte(`{"id":`)
+	b = appendJSONString(b, DeviceID)
+	b = append(b, `,"firmware":`...)
+	b = appendJSONString(b, FirmwareVersion)
+	b = append(b, `,"leds":`...)
+	b = strconv.AppendInt(b, NUMLEDS, 10)
+	b = append(b, `,"games":[`...)
+	for i, id := range playableGames() {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = appendJSONString(b, games[id].Name())
+	}
+	b = append(b, `],"topics":{`...)
+	for i, name := range announcedNames {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = appendJSONString(b, name)
+		b = append(b, ':')
+		b = appendJSONString(b, topic.For(DeviceID, name))
+	}
+	b = append(b, `},"capabilities":[`...)
+	for i, c := range capabilities {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = appendJSONString(b, c)
+	}
+	return append(b, "]}"...)
+}
+
+// appendJSONString appends s to b as a JSON string. strconv.AppendQuote is
+// Go syntax, its \x and \U escapes are not JSON.
+func appendJSONString(b []byte, s string) []byte {
+	const hex = "0123456789abcdef"
+	b = append(b, '"')
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c >= utf8.RuneSelf {
+			r, size := utf8.DecodeRuneInString(s[i:])
+			if r == utf8.RuneError && size == 1 {
+				b = append(b, `\ufffd`...)
+			} else {
+				b = append(b, s[i:i+size]...)
+			}
+			i += size
+			continue
+		}
+		switch {
+		case c == '"' || c == '\\':
+			b = append(b, '\\', c)
+		case c == '\n':
+			b = append(b, '\\', 'n')
+		case c == '\r':
+			b = append(b, '\\', 'r')
+		case c == '\t':
+			b = append(b, '\\', 't')
+		case c < 0x20 || c == 0x7f:
+			b = append(b, '\\', 'u', '0', '0', hex[c>>4], hex[c&0xf])
+		default:
+			b = append(b, c)
+		}
+		i++
+	}
+	return append(b, '"')
+}
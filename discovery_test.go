@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/conejoninja/vision/topic"
+)
+
+func TestAppendJSONString(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"vision7",
+		`say "hi"`,
+		`back\slash`,
+		"tab\tline\nfeed\r",
+		"\x00\x01\x1f\x7f",
+		"ñandú 🐹",
+		"bad \xff utf-8",
+	} {
+		b := appendJSONString(nil, s)
+		var got string
+		if err := json.Unmarshal(b, &got); err != nil {
+			t.Errorf("%q: %s is not JSON: %v", s, b, err)
+			continue
+		}
+		want := s
+		if s == "bad \xff utf-8" {
+			want = "bad � utf-8"
+		}
+		if got != want {
+			t.Errorf("%q: %s reads back as %q", s, b, got)
+		}
+	}
+}
+
+func TestDiscoveryJSON(t *testing.T) {
+	defer func(id string) { DeviceID = id }(DeviceID)
+	DeviceID = "desk \"7\"\x01"
+
+	var got struct {
+		ID       string            `json:"id"`
+		Leds     int               `json:"leds"`
+		Games    []string          `json:"games"`
+		Topics   map[string]string `json:"topics"`
+		Features []string          `json:"capabilities"`
+	}
+	if err := json.Unmarshal(discoveryJSON(), &got); err != nil {
+		t.Fatalf("%s: %v", discoveryJSON(), err)
+	}
+	if got.ID != DeviceID || got.Leds != NUMLEDS {
+		t.Errorf("id %q with %d LEDs, want %q with %d", got.ID, got.Leds, DeviceID, NUMLEDS)
+	}
+	if len(got.Games) != len(playableGames()) {
+		t.Errorf("games %v", got.Games)
+	}
+	if want := topic.For(DeviceID, topic.Command); got.Topics[topic.Command] != want {
+		t.Errorf("command topic %q, want %q", got.Topics[topic.Command], want)
+	}
+	if len(got.Features) != len(capabilities) {
+		t.Errorf("capabilities %v", got.Features)
+	}
+}
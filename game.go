@@ -0,0 +1,98 @@
+package main
+
+import (
+	"image/color"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/conejoninja/vision/controls"
+	"github.com/conejoninja/vision/logging"
+)
+
+const (
+	NORTH = iota
+	CIRCLE
+	MAZE
+	GAMEOVER
+)
+
+// Input is the snapshot of the headset a game gets on every frame.
+type Input struct {
+	Heading, OffsetHeading, LEDIndex int
+	HeadingRads, OffsetHeadingRads   float64
+	// RawHeadingRads is the heading before smoothing, for games that
+	// would rather have it fast than steady
+	RawHeadingRads float64
+	// Pitch and Roll are the tilt of the head in radians
+	Pitch, Roll float64
+	Pressed     [6]bool
+	// Events are the button events of the frame, see controls.Buttons
+	Events     []controls.Event
+	JoyX, JoyY uint16
+	// StickX and StickY are where the joystick points, right and forward
+	// from -1 to 1, see controls.Stick
+	StickX, StickY float64
+	// DT is the time the update has to advance the game by
+	DT time.Duration
+}
+
+// Game is one of the things the headset can play. Games live in their own
+// file and add themselves to the registry with registerGame from init.
+type Game interface {
+	// Name is shown to the player.
+	Name() string
+	// Init resets the game to its starting state.
+	Init()
+	// Update advances the game by in.DT. It is called at a fixed rate, and
+	// more than once per frame when a frame runs late.
+	Update(in *Input)
+	// Render draws the game into the LED frame, which comes in cleared.
+	Render(leds []color.RGBA)
+	// Publish sends the state of the game over MQTT.
+	Publish()
+}
+
+var games = map[int]Game{}
+
+func registerGame(id int, g Game) {
+	if _, ok := games[id]; ok {
+		panic("game registered twice: " + g.Name())
+	}
+	games[id] = g
+}
+
+// hiddenGame is implemented by games that are only reached from other games
+// and should not be offered to the player.
+type hiddenGame interface {
+	Hidden() bool
+}
+
+// playableGames returns the ids of the games the player can pick, in order.
+func playableGames() []int {
+	ids := make([]int, 0, len(games))
+	for id, g := range games {
+		if h, ok := g.(hiddenGame); ok && h.Hidden() {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// switchGame starts the game with the given id from scratch.
+func switchGame(id int) {
+	g, ok := games[id]
+	if !ok {
+		if gameLog.Enabled(logging.Error) {
+			gameLog.Error("unknown game", strconv.Itoa(id))
+		}
+		return
+	}
+	if racing && id != MAZE {
+		leaveRace()
+	}
+	game = id
+	g.Init()
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"image/color"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/conejoninja/vision/hal"
+	"github.com/conejoninja/vision/settings"
+)
+
+// testBoard is the headset wired to the hal fakes.
+type testBoard struct {
+	mag      *hal.FakeMagnetometer
+	accel    *hal.FakeAccelerometer
+	strip    *hal.FakeStrip
+	display  *hal.Framebuffer
+	buttons  hal.FakeButtons
+	joyX     *hal.FakeADC
+	joyY     *hal.FakeADC
+	joyRestX hal.FakeADC
+	joyRestY hal.FakeADC
+}
+
+// newTestBoard wires the fakes into the globals main would set up, with the
+// headset level and looking along the field, and starts game id.
+func newTestBoard(t *testing.T, id int) *testBoard {
+	t.Helper()
+	b := &testBoard{
+		mag:      &hal.FakeMagnetometer{},
+		accel:    &hal.FakeAccelerometer{Y: 1000000},
+		strip:    &hal.FakeStrip{},
+		display:  hal.NewFramebuffer(128, 64),
+		buttons:  make(hal.FakeButtons, 6),
+		joyRestX: 32768,
+		joyRestY: 32768,
+	}
+	x, y := b.joyRestX, b.joyRestY
+	b.joyX, b.joyY = &x, &y
+	b.look(0)
+
+	sensor = b.mag
+	accel = b.accel
+	strip = b.strip
+	display = b.display
+	buttons = b.buttons
+	joystick = hal.AnalogJoystick{X: b.joyX, Y: b.joyY}
+	storage = &settings.MemoryStorage{}
+
+	ledBytes = make([]byte, NUMLEDS*3)
+	stick.Joystick = joystick
+	stick.Calibrate()
+	buttonEvents.Buttons = buttons
+	loadSettings()
+	headingFilter.Reset()
+	ledHysteresis.Reset()
+	mode = IDLE
+	switchGame(id)
+	return b
+}
+
+// look turns the field so the headset faces angle radians away from north.
+func (b *testBoard) look(angle float64) {
+	// the sensor is mounted sideways: its X axis is the headset's Y and
+	// its Z axis the headset's X, see readSensors
+	b.mag.Z = int32(400 * math.Cos(angle))
+	b.mag.X = int32(-400 * math.Sin(angle))
+	b.mag.Y = 0
+}
+
+// run plays n frames of one step each.
+func (b *testBoard) run(t *testing.T, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		if err := loop(1); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// lit returns the LEDs of the last frame that are not black.
+func (b *testBoard) lit() []int {
+	var on []int
+	for i, c := range b.strip.Frame {
+		if c != colors[BLACK] && c != (color.RGBA{}) {
+			on = append(on, i)
+		}
+	}
+	return on
+}
+
+func TestNorthLightsOneLED(t *testing.T) {
+	b := newTestBoard(t, NORTH)
+
+	seen := map[int]bool{}
+	for _, angle := range []float64{0, 0.5, 1, -0.5, -1} {
+		b.look(angle)
+		b.run(t, 40)
+		on := b.lit()
+		if len(on) != 1 {
+			t.Fatalf("looking at %.1f: lit %v, want one LED", angle, on)
+		}
+		if c := b.strip.Frame[on[0]]; c != colors[RED] {
+			t.Errorf("looking at %.1f: LED %d is %v, want red", angle, on[0], c)
+		}
+		if seen[on[0]] {
+			t.Errorf("looking at %.1f: LED %d already lit for another heading", angle, on[0])
+		}
+		seen[on[0]] = true
+	}
+}
+
+func TestNorthHoldsStillOnNoise(t *testing.T) {
+	b := newTestBoard(t, NORTH)
+	b.look(0.3)
+	b.run(t, 40)
+	want := b.lit()
+
+	for i := 0; i < 100; i++ {
+		// a few units of sensor noise must not move the LED
+		b.mag.Z += int32(i%3 - 1)
+		b.mag.X -= int32(i%3 - 1)
+		b.run(t, 1)
+		if got := b.lit(); len(got) != 1 || got[0] != want[0] {
+			t.Fatalf("frame %d: lit %v, want %v", i, got, want)
+		}
+	}
+}
+
+// circleRounds is how many frames the circle takes to close in.
+const circleRounds = (300-56)*int(time.Second/frameTime)/circleShrink + 2
+
+func TestCircleEndsWhenTheArcCoversTheView(t *testing.T) {
+	for _, covered := range []bool{true, false} {
+		b := newTestBoard(t, CIRCLE)
+		b.run(t, 1)
+		g := games[CIRCLE].(*circleGame)
+		arc := g.arc
+		if !pickOrientation(g, covered) {
+			t.Fatalf("no orientation with LED 13 covered=%v", covered)
+		}
+
+		b.run(t, 1)
+		for _, i := range b.lit() {
+			if !circleCovers(g, i) {
+				t.Errorf("LED %d lit outside the arc", i)
+			}
+		}
+
+		b.run(t, circleRounds)
+		if covered && game != GAMEOVER {
+			t.Errorf("arc over the view: game %s, want GAMEOVER", games[game].Name())
+		}
+		if !covered && (game != CIRCLE || g.arc != arc+1) {
+			t.Errorf("arc away from the view: game %s arc %d, want CIRCLE arc %d", games[game].Name(), g.arc, arc+1)
+		}
+	}
+}
+
+// pickOrientation turns the arc of g so it covers LED 13 or not.
+func pickOrientation(g *circleGame, covered bool) bool {
+	for o := 0; o < NUMLEDS*2; o++ {
+		g.orientation = byte(o)
+		if circleCovers(g, 13) == covered {
+			return true
+		}
+	}
+	return false
+}
+
+func circleCovers(g *circleGame, led int) bool {
+	for i := byte(0); i < g.arc; i++ {
+		if g.index(i) == led {
+			return true
+		}
+	}
+	return false
+}
+
+func TestMazeWalksWithTheStick(t *testing.T) {
+	b := newTestBoard(t, MAZE)
+	b.run(t, 5)
+	if len(b.lit()) == 0 {
+		t.Fatal("no walls shown")
+	}
+
+	for _, push := range []hal.FakeADC{0, 65535} {
+		b.run(t, 1)
+		x0, y0 := px, py
+		*b.joyY = push
+		b.run(t, 10)
+		*b.joyY = b.joyRestY
+
+		if px == x0 && py == y0 {
+			t.Errorf("stick at %d: player did not move from %d,%d", push, x0, y0)
+		}
+		if tx, ty := level.Grid.Tile(px, py); level.Grid.Wall(tx, ty) {
+			t.Errorf("stick at %d: player inside the wall at %d,%d", push, tx, ty)
+		}
+	}
+
+	// at rest it stays put
+	x0, y0 := px, py
+	b.run(t, 10)
+	if px != x0 || py != y0 {
+		t.Errorf("stick at rest: player moved from %d,%d to %d,%d", x0, y0, px, py)
+	}
+}
+
+// press holds button down long enough to get through the debounce and
+// lets it go.
+func (b *testBoard) press(t *testing.T, button int) {
+	t.Helper()
+	b.buttons[button] = true
+	b.run(t, 2)
+	b.buttons[button] = false
+	b.run(t, 2)
+}
+
+func TestCalibrationKeepsTheMaze(t *testing.T) {
+	b := newTestBoard(t, MAZE)
+	b.run(t, 2)
+	seed, x0, y0 := mazeSeed, px, py
+
+	startCalibration()
+	for i := 0; i < 8; i++ {
+		b.look(float64(i) * math.Pi / 4)
+		b.mag.Y = int32(300 * (i%3 - 1))
+		// pushing the stick while turning around must not walk
+		*b.joyY = 0
+		b.run(t, 1)
+	}
+	*b.joyY = b.joyRestY
+	if !calibrating.Valid() {
+		t.Fatal("calibration did not see enough of the field")
+	}
+
+	// MID finishes calibration, and only that
+	b.press(t, MID)
+	if mode != IDLE {
+		t.Fatalf("mode %s after MID, want IDLE", modeNames[mode])
+	}
+	if mazeSeed != seed || px != x0 || py != y0 {
+		t.Errorf("maze %d at %d,%d, want %d at %d,%d", mazeSeed, px, py, seed, x0, y0)
+	}
+}
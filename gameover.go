@@ -0,0 +1,48 @@
+package main
+
+import (
+	"image/color"
+	"time"
+)
+
+const (
+	gameOverBlinks = 5
+	// how long each blink stays on, and then off
+	gameOverBlink = 600 * time.Millisecond
+)
+
+func init() {
+	registerGame(GAMEOVER, &gameOverGame{})
+}
+
+// gameOverGame blinks every LED red and then goes back to CIRCLE.
+type gameOverGame struct {
+	elapsed time.Duration
+}
+
+func (g *gameOverGame) Name() string { return "GAMEOVER" }
+
+// Hidden keeps GAMEOVER out of the menu, it only follows a lost CIRCLE.
+func (g *gameOverGame) Hidden() bool { return true }
+
+func (g *gameOverGame) Init() {
+	g.elapsed = 0
+}
+
+func (g *gameOverGame) Update(in *Input) {
+	g.elapsed += in.DT
+	if g.elapsed >= 2*gameOverBlinks*gameOverBlink {
+		switchGame(CIRCLE)
+	}
+}
+
+func (g *gameOverGame) Render(leds []color.RGBA) {
+	if (g.elapsed/gameOverBlink)%2 != 0 {
+		return
+	}
+	for i := range leds {
+		leds[i] = colors[RED]
+	}
+}
+
+func (g *gameOverGame) Publish() {}
@@ -0,0 +1,167 @@
+package grid
+
+// Level is a maze ready to be played: the grid, the tile the player starts
+// on and the tile that gets them out.
+type Level struct {
+	Grid *Grid
+	// Seed is what Generate built the level from, 0 for hand-made ones.
+	Seed           uint32
+	StartX, StartY int
+	ExitX, ExitY   int
+}
+
+// NewLevel wraps a hand-made grid into a level starting at tile (sx, sy),
+// with the exit on the reachable tile furthest away from it.
+func NewLevel(g *Grid, sx, sy int) *Level {
+	l := &Level{Grid: g, StartX: sx, StartY: sy}
+	l.ExitX, l.ExitY, _ = g.Furthest(sx, sy)
+	return l
+}
+
+// Generate builds a width x height maze from seed with a recursive
+// backtracker, so there is exactly one path between any two open tiles.
+// Both sizes are rounded down to an odd number, at least 3, to keep walls
+// between the corridors and around the border. The same seed and size always
+// give the same maze, on any device.
+func Generate(seed uint32, width, height, tileSize int) *Level {
+	width = oddSize(width)
+	height = oddSize(height)
+	g := New(width, height, tileSize)
+	for i := range g.walls {
+		g.walls[i] = true
+	}
+
+	// corridors run through the odd tiles, cells are numbered in halves
+	cw, ch := width/2, height/2
+	visited := make([]bool, cw*ch)
+	stack := make([]int, 1, cw*ch)
+	rng := newRand(seed)
+	g.Set(1, 1, false)
+	visited[0] = true
+
+	var options [4]int
+	for len(stack) > 0 {
+		c := stack[len(stack)-1]
+		cx, cy := c%cw, c/cw
+		n := 0
+		for _, d := range [4][2]int{{0, -1}, {1, 0}, {0, 1}, {-1, 0}} {
+			nx, ny := cx+d[0], cy+d[1]
+			if nx >= 0 && ny >= 0 && nx < cw && ny < ch && !visited[ny*cw+nx] {
+				options[n] = ny*cw + nx
+				n++
+			}
+		}
+		if n == 0 {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		next := options[rng.intn(n)]
+		nx, ny := next%cw, next/cw
+		// knock down the wall between both cells
+		g.Set(cx+nx+1, cy+ny+1, false)
+		g.Set(2*nx+1, 2*ny+1, false)
+		visited[next] = true
+		stack = append(stack, next)
+	}
+
+	l := NewLevel(g, 1, 1)
+	l.Seed = seed
+	return l
+}
+
+func oddSize(n int) int {
+	if n < 3 {
+		return 3
+	}
+	if n%2 == 0 {
+		n--
+	}
+	return n
+}
+
+// Furthest returns the open tile reachable from (x, y) that takes the most
+// steps to walk to, and how many steps that is.
+func (g *Grid) Furthest(x, y int) (fx, fy, steps int) {
+	dist := g.distances(x, y)
+	fx, fy, steps = x, y, 0
+	for i, d := range dist {
+		if d > steps {
+			fx, fy, steps = i%g.Width, i/g.Width, d
+		}
+	}
+	return fx, fy, steps
+}
+
+// Solvable reports whether tile (ex, ey) can be walked to from (sx, sy)
+// without going through walls, moving one tile north, south, east or west
+// at a time.
+func (g *Grid) Solvable(sx, sy, ex, ey int) bool {
+	if g.Wall(sx, sy) || g.Wall(ex, ey) {
+		return false
+	}
+	return g.distances(sx, sy)[ey*g.Width+ex] >= 0
+}
+
+// Solvable reports whether the exit of the level can be reached from its
+// start.
+func (l *Level) Solvable() bool {
+	return l.Grid.Solvable(l.StartX, l.StartY, l.ExitX, l.ExitY)
+}
+
+// distances walks the grid breadth first from (x, y) and returns how many
+// steps away every tile is, -1 for the ones that cannot be reached.
+func (g *Grid) distances(x, y int) []int {
+	dist := make([]int, len(g.walls))
+	for i := range dist {
+		dist[i] = -1
+	}
+	if g.Wall(x, y) {
+		return dist
+	}
+	queue := make([]int, 1, len(g.walls))
+	queue[0] = y*g.Width + x
+	dist[queue[0]] = 0
+	for len(queue) > 0 {
+		c := queue[0]
+		queue = queue[1:]
+		cx, cy := c%g.Width, c/g.Width
+		for _, d := range [4][2]int{{0, -1}, {1, 0}, {0, 1}, {-1, 0}} {
+			nx, ny := cx+d[0], cy+d[1]
+			if g.Wall(nx, ny) {
+				continue
+			}
+			n := ny*g.Width + nx
+			if dist[n] < 0 {
+				dist[n] = dist[c] + 1
+				queue = append(queue, n)
+			}
+		}
+	}
+	return dist
+}
+
+// rand is a xorshift32 generator. It is spelled out here instead of using
+// math/rand so a seed builds the same maze on the headset, on a laptop and
+// with any version of Go.
+type rand struct {
+	state uint32
+}
+
+func newRand(seed uint32) *rand {
+	if seed == 0 {
+		// xorshift gets stuck on zero
+		seed = 0x9e3779b9
+	}
+	return &rand{state: seed}
+}
+
+func (r *rand) next() uint32 {
+	r.state ^= r.state << 13
+	r.state ^= r.state >> 17
+	r.state ^= r.state << 5
+	return r.state
+}
+
+func (r *rand) intn(n int) int {
+	return int(r.next() % uint32(n))
+}
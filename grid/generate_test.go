@@ -0,0 +1,117 @@
+package grid
+
+import (
+	"testing"
+)
+
+func TestGenerateIsSolvable(t *testing.T) {
+	for _, size := range []int{3, 5, 11, 15, 21, 31, 63} {
+		for seed := uint32(1); seed <= 50; seed++ {
+			l := Generate(seed, size, size, tile)
+			if !l.Solvable() {
+				t.Fatalf("seed %d size %d: exit %d,%d cannot be reached", seed, size, l.ExitX, l.ExitY)
+			}
+			if size > 3 && l.ExitX == l.StartX && l.ExitY == l.StartY {
+				t.Errorf("seed %d size %d: exit on the start", seed, size)
+			}
+			checkBorder(t, l.Grid)
+		}
+	}
+}
+
+// checkBorder fails when the border of g has an opening.
+func checkBorder(t *testing.T, g *Grid) {
+	t.Helper()
+	for x := 0; x < g.Width; x++ {
+		if !g.Wall(x, 0) || !g.Wall(x, g.Height-1) {
+			t.Fatalf("opening in the border at column %d", x)
+		}
+	}
+	for y := 0; y < g.Height; y++ {
+		if !g.Wall(0, y) || !g.Wall(g.Width-1, y) {
+			t.Fatalf("opening in the border at row %d", y)
+		}
+	}
+}
+
+func TestGenerateIsRepeatable(t *testing.T) {
+	a := Generate(1234, 21, 21, tile)
+	b := Generate(1234, 21, 21, tile)
+	c := Generate(1235, 21, 21, tile)
+	if !same(a.Grid, b.Grid) || a.ExitX != b.ExitX || a.ExitY != b.ExitY {
+		t.Error("the same seed built two different mazes")
+	}
+	if same(a.Grid, c.Grid) {
+		t.Error("two seeds built the same maze")
+	}
+	if a.Seed != 1234 {
+		t.Errorf("Seed %d, want 1234", a.Seed)
+	}
+}
+
+func same(a, b *Grid) bool {
+	if a.Width != b.Width || a.Height != b.Height {
+		return false
+	}
+	for y := 0; y < a.Height; y++ {
+		for x := 0; x < a.Width; x++ {
+			if a.Wall(x, y) != b.Wall(x, y) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func TestGenerateSize(t *testing.T) {
+	for _, tt := range []struct{ in, want int }{
+		{0, 3}, {3, 3}, {4, 3}, {11, 11}, {12, 11},
+	} {
+		if g := Generate(1, tt.in, tt.in, tile).Grid; g.Width != tt.want || g.Height != tt.want {
+			t.Errorf("size %d: got %dx%d, want %d", tt.in, g.Width, g.Height, tt.want)
+		}
+	}
+}
+
+func TestClassicLevel(t *testing.T) {
+	l := NewLevel(parse(classic), 2, 1)
+	if !l.Solvable() {
+		t.Fatalf("exit %d,%d cannot be reached", l.ExitX, l.ExitY)
+	}
+	if _, _, steps := l.Grid.Furthest(2, 1); steps == 0 {
+		t.Error("nowhere to go from the start")
+	}
+}
+
+func TestSolvable(t *testing.T) {
+	g := parse([]string{
+		"#######",
+		"#..#..#",
+		"#..#..#",
+		"#######",
+	})
+	for _, tt := range []struct {
+		sx, sy, ex, ey int
+		want           bool
+	}{
+		{1, 1, 2, 2, true},
+		{1, 1, 1, 1, true},
+		// through the wall
+		{1, 1, 4, 1, false},
+		// from or into a wall
+		{3, 1, 4, 1, false},
+		{1, 1, 3, 2, false},
+		// off the grid
+		{1, 1, 9, 9, false},
+	} {
+		if got := g.Solvable(tt.sx, tt.sy, tt.ex, tt.ey); got != tt.want {
+			t.Errorf("Solvable(%d,%d -> %d,%d) = %v, want %v", tt.sx, tt.sy, tt.ex, tt.ey, got, tt.want)
+		}
+	}
+
+	// opening the wall joins both rooms
+	g.Set(3, 2, false)
+	if !g.Solvable(1, 1, 4, 1) {
+		t.Error("not solvable through the opening")
+	}
+}
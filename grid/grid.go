@@ -0,0 +1,63 @@
+// Package grid is the tile map the maze is played on, and the geometry that
+// goes with it: casting rays and moving around without going through walls.
+//
+// Positions are in world units, integers so the maths stays cheap on the
+// microcontroller. A tile is TileSize units wide and tile (0, 0) covers
+// [0, TileSize) on both axes. X grows to the east and Y to the south.
+package grid
+
+// Grid is a map of square tiles, each of them either a wall or open.
+type Grid struct {
+	Width, Height int
+	TileSize      int
+	walls         []bool
+}
+
+// New returns an open grid of width x height tiles.
+func New(width, height, tileSize int) *Grid {
+	return &Grid{
+		Width:    width,
+		Height:   height,
+		TileSize: tileSize,
+		walls:    make([]bool, width*height),
+	}
+}
+
+// Inside reports whether tile (x, y) is part of the grid.
+func (g *Grid) Inside(x, y int) bool {
+	return x >= 0 && y >= 0 && x < g.Width && y < g.Height
+}
+
+// Wall reports whether tile (x, y) is a wall. Everything outside of the grid
+// is.
+func (g *Grid) Wall(x, y int) bool {
+	if !g.Inside(x, y) {
+		return true
+	}
+	return g.walls[y*g.Width+x]
+}
+
+// Set makes tile (x, y) a wall or opens it.
+func (g *Grid) Set(x, y int, wall bool) {
+	if g.Inside(x, y) {
+		g.walls[y*g.Width+x] = wall
+	}
+}
+
+// Tile returns the tile under the world position (x, y).
+func (g *Grid) Tile(x, y int) (tx, ty int) {
+	return floorDiv(x, g.TileSize), floorDiv(y, g.TileSize)
+}
+
+// WallAt reports whether the world position (x, y) is inside a wall.
+func (g *Grid) WallAt(x, y int) bool {
+	return g.Wall(g.Tile(x, y))
+}
+
+func floorDiv(a, b int) int {
+	q := a / b
+	if a%b != 0 && a < 0 {
+		q--
+	}
+	return q
+}
@@ -0,0 +1,50 @@
+package grid
+
+// Blocked reports whether a body centred on the world position (x, y)
+// overlaps a wall. The body is a square reaching radius units out on every
+// side, which is enough to stop it from cutting through wall corners.
+func (g *Grid) Blocked(x, y, radius int) bool {
+	x0, y0 := g.Tile(x-radius, y-radius)
+	x1, y1 := g.Tile(x+radius, y+radius)
+	for ty := y0; ty <= y1; ty++ {
+		for tx := x0; tx <= x1; tx++ {
+			if g.Wall(tx, ty) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Move moves a body of the given radius (see Blocked) from (x, y) by
+// (dx, dy) and returns where it ends up. Each axis is resolved on its own
+// and a blocked axis stops flush against the wall, so running into a wall at
+// an angle slides along it instead of stopping dead. Moves should be shorter
+// than a tile.
+func (g *Grid) Move(x, y, dx, dy, radius int) (nx, ny int) {
+	nx = g.moveAxis(x, dx, radius, func(v int) bool { return g.Blocked(v, y, radius) })
+	ny = g.moveAxis(y, dy, radius, func(v int) bool { return g.Blocked(nx, v, radius) })
+	return nx, ny
+}
+
+// moveAxis moves pos by d along one axis, backing off to the edge of the
+// tile in the way when blocked says the new position overlaps a wall.
+func (g *Grid) moveAxis(pos, d, radius int, blocked func(int) bool) int {
+	if d == 0 {
+		return pos
+	}
+	next := pos + d
+	if !blocked(next) {
+		return next
+	}
+	if d > 0 {
+		// stop just before the tile the leading edge went into
+		next = floorDiv(next+radius, g.TileSize)*g.TileSize - radius - 1
+	} else {
+		next = (floorDiv(next-radius, g.TileSize)+1)*g.TileSize + radius
+	}
+	if (d > 0 && next < pos) || (d < 0 && next > pos) || blocked(next) {
+		return pos
+	}
+	return next
+}
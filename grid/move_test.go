@@ -0,0 +1,82 @@
+package grid
+
+import (
+	"math"
+	"testing"
+)
+
+// room is two rows of open tiles with a pillar on tile (3, 1), which covers
+// x 900-1199 and y 300-599. Off the grid on the east is a wall too.
+var room = []string{
+	"#####",
+	"#..#.",
+	"#....",
+	"#####",
+}
+
+func TestMove(t *testing.T) {
+	g := parse(room)
+	const r = 60
+	for _, tt := range []struct {
+		name         string
+		x, y, dx, dy int
+		wantX, wantY int
+	}{
+		{"open floor", 450, 750, 40, 0, 490, 750},
+		{"east into the pillar stops flush", 780, 450, 80, 0, 900 - r - 1, 450},
+		{"west into the wall stops flush", 370, 450, -40, 0, 300 + r, 450},
+		{"north into the wall stops flush", 450, 370, 0, -40, 450, 300 + r},
+		{"south into the wall stops flush", 450, 820, 0, 40, 450, 900 - r - 1},
+		{"diagonal into the pillar slides south", 780, 450, 80, 40, 900 - r - 1, 490},
+		{"diagonal into the north wall slides east", 450, 370, 40, -40, 490, 300 + r},
+		{"already flush does not move", 900 - r - 1, 450, 40, 0, 900 - r - 1, 450},
+		// the body is square, its corner would cut into the pillar
+		{"corner of the pillar", 860, 700, 40, -80, 900, 600 + r},
+		{"standing still", 450, 450, 0, 0, 450, 450},
+	} {
+		x, y := g.Move(tt.x, tt.y, tt.dx, tt.dy, r)
+		if x != tt.wantX || y != tt.wantY {
+			t.Errorf("%s: moved to %d,%d, want %d,%d", tt.name, x, y, tt.wantX, tt.wantY)
+		}
+		if g.Blocked(x, y, r) {
+			t.Errorf("%s: ended inside a wall at %d,%d", tt.name, x, y)
+		}
+	}
+}
+
+func TestBlocked(t *testing.T) {
+	g := parse(room)
+	for _, tt := range []struct {
+		x, y, r int
+		want    bool
+	}{
+		{450, 450, 60, false},
+		{450, 450, 150, false},
+		{450, 450, 151, true},
+		{839, 450, 60, false},
+		{840, 450, 60, true},
+		// only the corner of the body reaches the pillar
+		{840, 661, 60, false},
+		{840, 659, 60, true},
+		{150, 150, 0, true},
+	} {
+		if got := g.Blocked(tt.x, tt.y, tt.r); got != tt.want {
+			t.Errorf("Blocked(%d, %d, %d) = %v, want %v", tt.x, tt.y, tt.r, got, tt.want)
+		}
+	}
+}
+
+func TestMoveNeverEntersWalls(t *testing.T) {
+	g := parse(classic)
+	const r = 60
+	x, y := center(2, 1)
+	for i := 0; i < 5000; i++ {
+		// wander with steps up to a third of a tile
+		angle := 2 * math.Pi * float64(i*7919%360) / 360
+		step := 10 + float64(i%10)*10
+		x, y = g.Move(x, y, int(step*math.Cos(angle)), int(step*math.Sin(angle)), r)
+		if g.Blocked(x, y, r) {
+			t.Fatalf("step %d: inside a wall at %d,%d", i, x, y)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package grid
+
+import (
+	"math"
+	"testing"
+)
+
+// classic is the hand-made maze MAZE starts with, # is a wall. The
+// player starts in tile (2, 1).
+var classic = []string{
+	"##########...#.#...#############",
+	"#........#...#.#...#.....##....#",
+	"#.###.##.#...#.#...#.###.##.##.#",
+	"#.###.##.#...#.#...#.###....##.#",
+	"#.###.##.#...#.#...#.######.##.#",
+	"#.###.##.#####.#####.######.##.#",
+	"#...........................##.#",
+	"#.###.########.######.##.#####.#",
+	"#.###.########.######.##.#####.#",
+	"#.##.....##...........##....##.#",
+	"#.##.###.##.######.##.##.##.##.#",
+	"#.##.###.##.#....#.##.##.##.##.#",
+	"#.....##.##.#....#.##.##.##.##.#",
+	"#.###.##....#....#.##.##.##.##.#",
+	"..###.#####.#....#.##....##.....",
+	"#.###.#####.#....#.#####.#####.#",
+	"#.###.#####.#....#.#####.#####.#",
+	"#.###.##....#....#.##....##....#",
+	"#.###.##.##.#....#.##.##.##.##.#",
+	"#.....##.##.#....#.##.##.##.##.#",
+	"#.##.###.##.#....#.##.##.##.##.#",
+	"#.##.###.##.######.##.##.##.##.#",
+	"#.##.....##...........##....##.#",
+	"#.###.########.######.##.#####.#",
+	"#.###.########.######.##.#####.#",
+	"#...........................##.#",
+	"#.###.##.#####.#####.######.##.#",
+	"#.###.##.#...#.#...#.######.##.#",
+	"#.###.##.#...#.#...#.###....##.#",
+	"#.###.##.#...#.#...#.###.##.##.#",
+	"#........#...#.#...#.....##....#",
+	"##########...#.#...#############",
+}
+
+const tile = 300
+
+// parse builds a grid from rows of # and anything else.
+func parse(rows []string) *Grid {
+	g := New(len(rows[0]), len(rows), tile)
+	for y, row := range rows {
+		for x := range row {
+			g.Set(x, y, row[x] == '#')
+		}
+	}
+	return g
+}
+
+// center returns the world position of the middle of tile (x, y).
+func center(x, y int) (int, int) {
+	return x*tile + tile/2, y*tile + tile/2
+}
+
+func TestCast(t *testing.T) {
+	g := parse(classic)
+	for _, tt := range []struct {
+		name   string
+		tx, ty int
+		angle  float64
+		max    int
+		want   Hit
+	}{
+		{"east along the first row", 2, 1, 0, 5000, Hit{1950, 9, 1, West}},
+		{"west to the outer wall", 2, 1, math.Pi, 5000, Hit{450, 0, 1, East}},
+		{"north to the outer wall", 2, 1, -math.Pi / 2, 5000, Hit{150, 2, 0, South}},
+		{"south into the block", 2, 1, math.Pi / 2, 5000, Hit{150, 2, 2, North}},
+		{"down the west corridor", 1, 1, math.Pi / 2, 10000, Hit{8850, 1, 31, North}},
+		{"along the long corridor", 1, 6, 0, 10000, Hit{7950, 28, 6, West}},
+		{"out of the open side", 1, 14, math.Pi, 5000, Hit{450, -1, 14, East}},
+		{"diagonal onto a corner", 2, 1, math.Pi / 4, 5000, Hit{212, 2, 2, North}},
+		{"out of distance", 2, 1, 0, 1000, Hit{1000, 6, 1, NoFace}},
+		{"inside a wall", 0, 0, 0, 5000, Hit{0, 0, 0, NoFace}},
+	} {
+		x, y := center(tt.tx, tt.ty)
+		dx, dy := Direction(tt.angle)
+		if got := g.Cast(x, y, dx, dy, tt.max); got != tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// march is the slow caster the DDA replaced: it samples the ray every unit.
+func march(g *Grid, x, y int, angle float64, maxDist int) (dist, tx, ty int) {
+	s, c := math.Sincos(angle)
+	for d := 0; d < maxDist; d++ {
+		tx, ty = g.Tile(int(math.Floor(float64(x)+c*float64(d))), int(math.Floor(float64(y)+s*float64(d))))
+		if g.Wall(tx, ty) {
+			return d, tx, ty
+		}
+	}
+	return maxDist, tx, ty
+}
+
+func TestCastMatchesMarching(t *testing.T) {
+	g := parse(classic)
+	starts := [][2]int{{2, 1}, {1, 6}, {14, 14}, {30, 30}, {22, 9}}
+	const rays = 360
+	for _, s := range starts {
+		x, y := center(s[0], s[1])
+		for i := 0; i < rays; i++ {
+			// keep off the exact diagonals, where a ray through a corner
+			// touches two tiles at once
+			angle := 2*math.Pi*float64(i)/rays + 0.001
+			dx, dy := Direction(angle)
+			hit := g.Cast(x, y, dx, dy, 3000)
+			dist, tx, ty := march(g, x, y, angle, 3000)
+			if d := hit.Dist - dist; d < -1 || d > 1 {
+				t.Errorf("from %v at %.3f: DDA %d, marching %d", s, angle, hit.Dist, dist)
+			}
+			if hit.Face != NoFace && (hit.X != tx || hit.Y != ty) {
+				t.Errorf("from %v at %.3f: DDA hit %d,%d, marching %d,%d", s, angle, hit.X, hit.Y, tx, ty)
+			}
+		}
+	}
+}
+
+func BenchmarkCast(b *testing.B) {
+	g := parse(classic)
+	x, y := center(2, 1)
+	// one frame of the LED view: a ray per LED over half a turn
+	const rays = 44
+	var dirs [rays][2]int32
+	for i := range dirs {
+		dirs[i][0], dirs[i][1] = Direction(math.Pi * float64(i) / rays)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, d := range dirs {
+			g.Cast(x, y, d[0], d[1], 3000)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package hal
+
+import (
+	"image/color"
+)
+
+// FakeMagnetometer returns whatever field it has been told to.
+type FakeMagnetometer struct {
+	X, Y, Z int32
+	Err     error
+}
+
+func (m *FakeMagnetometer) ReadMagneticField() (x, y, z int32, err error) {
+	return m.X, m.Y, m.Z, m.Err
+}
+
+// FakeAccelerometer returns whatever acceleration it has been told to.
+type FakeAccelerometer struct {
+	X, Y, Z int32
+	Err     error
+}
+
+func (a *FakeAccelerometer) ReadAcceleration() (x, y, z int32, err error) {
+	return a.X, a.Y, a.Z, a.Err
+}
+
+// FakeStrip keeps a copy of the last frame written to it.
+type FakeStrip struct {
+	Frame  []color.RGBA
+	Writes int
+}
+
+func (s *FakeStrip) WriteColors(buf []color.RGBA) error {
+	s.Frame = append(s.Frame[:0], buf...)
+	s.Writes++
+	return nil
+}
+
+// Framebuffer is an in-memory monochrome display.
+type Framebuffer struct {
+	Width, Height int16
+	Pixels        []bool
+	Displays      int
+}
+
+// NewFramebuffer returns a blank framebuffer of the given size.
+func NewFramebuffer(width, height int16) *Framebuffer {
+	return &Framebuffer{
+		Width:  width,
+		Height: height,
+		Pixels: make([]bool, int(width)*int(height)),
+	}
+}
+
+func (f *Framebuffer) Size() (x, y int16) {
+	return f.Width, f.Height
+}
+
+func (f *Framebuffer) SetPixel(x, y int16, c color.RGBA) {
+	if x < 0 || x >= f.Width || y < 0 || y >= f.Height {
+		return
+	}
+	// same rule as the ssd1306 driver: anything not black is lit
+	f.Pixels[int(y)*int(f.Width)+int(x)] = c.R != 0 || c.G != 0 || c.B != 0
+}
+
+func (f *Framebuffer) GetPixel(x, y int16) bool {
+	if x < 0 || x >= f.Width || y < 0 || y >= f.Height {
+		return false
+	}
+	return f.Pixels[int(y)*int(f.Width)+int(x)]
+}
+
+func (f *Framebuffer) ClearDisplay() {
+	for i := range f.Pixels {
+		f.Pixels[i] = false
+	}
+}
+
+func (f *Framebuffer) Display() error {
+	f.Displays++
+	return nil
+}
+
+// FakeButtons are buttons pressed and released by setting the slice.
+type FakeButtons []bool
+
+func (b FakeButtons) Len() int { return len(b) }
+
+func (b FakeButtons) Pressed(i int) bool { return b[i] }
+
+// FakeADC always reads its own value.
+type FakeADC uint16
+
+func (a *FakeADC) Get() uint16 { return uint16(*a) }
@@ -0,0 +1,75 @@
+// Package hal describes the pieces of hardware the headset is built from, so
+// the game loop can run against the real drivers on the board or against the
+// fakes in this package on a host.
+package hal
+
+import (
+	"image/color"
+
+	"tinygo.org/x/drivers"
+)
+
+// Magnetometer returns the raw magnetic field, in the sensor's own axes.
+// *lsm303agr.Device satisfies it.
+type Magnetometer interface {
+	ReadMagneticField() (x, y, z int32, err error)
+}
+
+// Accelerometer returns the acceleration, in the sensor's own axes. At rest
+// it is the reaction to gravity, pointing up. *lsm303agr.Device satisfies it.
+type Accelerometer interface {
+	ReadAcceleration() (x, y, z int32, err error)
+}
+
+// LEDStrip is the addressable LED strip in front of the eyes.
+// ws2812.Device satisfies it.
+type LEDStrip interface {
+	WriteColors(buf []color.RGBA) error
+}
+
+// Display is the monochrome OLED. *ssd1306.Device satisfies it.
+type Display interface {
+	drivers.Displayer
+	ClearDisplay()
+	GetPixel(x, y int16) bool
+}
+
+// Pin is a digital input. machine.Pin satisfies it.
+type Pin interface {
+	Get() bool
+}
+
+// ADC is an analog input. machine.ADC satisfies it.
+type ADC interface {
+	Get() uint16
+}
+
+// Buttons is the set of push buttons, indexed in the order they were wired.
+type Buttons interface {
+	Len() int
+	// Pressed reports whether button i is being held down right now.
+	Pressed(i int) bool
+}
+
+// Joystick is an analog stick. Each axis goes from 0 to 65535 and rests
+// around the middle.
+type Joystick interface {
+	Get() (x, y uint16)
+}
+
+// PinButtons are buttons wired between a pin with pull-up and ground, so a
+// pressed button reads low.
+type PinButtons []Pin
+
+func (b PinButtons) Len() int { return len(b) }
+
+func (b PinButtons) Pressed(i int) bool { return !b[i].Get() }
+
+// AnalogJoystick is a joystick made of two potentiometers read through ADCs.
+type AnalogJoystick struct {
+	X, Y ADC
+}
+
+func (j AnalogJoystick) Get() (x, y uint16) {
+	return j.X.Get(), j.Y.Get()
+}
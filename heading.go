@@ -0,0 +1,45 @@
+package main
+
+import (
+	"time"
+
+	"github.com/conejoninja/vision/compass"
+)
+
+var (
+	// pitch and roll are the tilt of the head, in radians.
+	pitch, roll float64
+	// rawHeadingRads is the heading straight from the sensors, headingRads
+	// is the same after going through headingFilter.
+	rawHeadingRads float64
+
+	// headingResponse is how long the smoothed heading takes to catch up
+	// with the head, longer is steadier but laggier.
+	headingResponse = 100 * time.Millisecond
+	headingFilter   compass.Filter
+	// ledHysteresis keeps the lit LED from flickering between neighbours,
+	// one cell per LED over half a turn.
+	ledHysteresis = compass.Hysteresis{Steps: 2 * NUMLEDS, Margin: 0.3}
+)
+
+// readSensors reads the magnetometer and the accelerometer and updates
+// headingRads, rawHeadingRads, pitch and roll, dt after the previous read. It
+// returns the raw magnetic field for calibration.
+func readSensors(dt time.Duration) (rx, ry, rz int32, err error) {
+	rx, ry, rz, err = sensor.ReadMagneticField()
+	if err != nil {
+		return
+	}
+	ax, ay, az, err := accel.ReadAcceleration()
+	if err != nil {
+		return
+	}
+
+	// the sensor is mounted sideways, its Y axis points up
+	my, mz, mx := calibration.Apply(rx, ry, rz)
+	rawHeadingRads, pitch, roll = compass.TiltCompensated(mx, my, mz, float64(az), float64(ax), float64(ay))
+
+	headingFilter.Alpha = compass.AlphaFor(headingResponse, dt)
+	headingRads = headingFilter.Update(rawHeadingRads)
+	return
+}
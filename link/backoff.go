@@ -0,0 +1,55 @@
+package link
+
+import (
+	"time"
+)
+
+// Backoff is an exponential backoff with jitter. Every wait doubles up to
+// Max, and a random part of it is taken off so devices that lost the same
+// broker do not all come back at once.
+type Backoff struct {
+	Min, Max time.Duration
+	// Jitter is the fraction of each wait that is random, from 0 to 1.
+	Jitter float64
+	// Seed starts the random sequence, zero picks a fixed one.
+	Seed uint32
+
+	next time.Duration
+	rand uint32
+}
+
+// Next returns how long to wait before the next attempt.
+func (b *Backoff) Next() time.Duration {
+	if b.next < b.Min {
+		b.next = b.Min
+	}
+	d := b.next
+	b.next *= 2
+	if b.Max > 0 && b.next > b.Max {
+		b.next = b.Max
+	}
+	if b.Jitter > 0 {
+		d -= time.Duration(float64(d) * b.Jitter * b.random())
+	}
+	return d
+}
+
+// Reset goes back to the shortest wait, after a success.
+func (b *Backoff) Reset() {
+	b.next = 0
+}
+
+// random returns a number in [0, 1).
+func (b *Backoff) random() float64 {
+	if b.rand == 0 {
+		b.rand = b.Seed
+		if b.rand == 0 {
+			b.rand = 0x9e3779b9
+		}
+	}
+	// xorshift32
+	b.rand ^= b.rand << 13
+	b.rand ^= b.rand >> 17
+	b.rand ^= b.rand << 5
+	return float64(b.rand) / (1 << 32)
+}
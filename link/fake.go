@@ -0,0 +1,223 @@
+package link
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sync"
+
+	mqtt "github.com/soypat/natiu-mqtt"
+)
+
+// Broker is an in-process stand-in for an MQTT broker. It accepts every
+// connection, acknowledges subscriptions, answers pings and remembers what
+// was published, which is enough to run the supervisor against without a
+// network.
+type Broker struct {
+	mu        sync.Mutex
+	mute      bool
+	conns     []net.Conn
+	txs       []*mqtt.Tx
+	subs      [][]string
+	published []Message
+	retained  map[string]Message
+}
+
+// Conn returns the client end of a new connection to the broker.
+func (b *Broker) Conn() io.ReadWriteCloser {
+	client, server := net.Pipe()
+	tx := &mqtt.Tx{}
+	tx.SetTxTransport(server)
+	b.mu.Lock()
+	b.conns = append(b.conns, server)
+	b.txs = append(b.txs, tx)
+	b.subs = append(b.subs, nil)
+	i := len(b.conns) - 1
+	b.mu.Unlock()
+	go b.serve(i, server, tx)
+	return client
+}
+
+// Mute stops or resumes answering pings, like a broker that hung.
+func (b *Broker) Mute(mute bool) {
+	b.mu.Lock()
+	b.mute = mute
+	b.mu.Unlock()
+}
+
+// Drop closes every connection, like a broker that restarted.
+func (b *Broker) Drop() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, c := range b.conns {
+		c.Close()
+	}
+}
+
+// Published returns a copy of everything published so far.
+func (b *Broker) Published() []Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]Message(nil), b.published...)
+}
+
+// Retained returns the message the broker keeps for topic.
+func (b *Broker) Retained(topic string) (Message, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	m, ok := b.retained[topic]
+	return m, ok
+}
+
+// publish records m as if a client published it.
+func (b *Broker) publish(m Message) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.published = append(b.published, m)
+	if m.Retain {
+		if b.retained == nil {
+			b.retained = make(map[string]Message)
+		}
+		b.retained[m.Topic] = m
+	}
+}
+
+// Send publishes payload on topic to every connection subscribed to it.
+func (b *Broker) Send(topic string, payload []byte) {
+	flags, _ := mqtt.NewPublishFlags(mqtt.QoS0, false, false)
+	vp := mqtt.VariablesPublish{TopicName: []byte(topic)}
+	hdr, _ := mqtt.NewHeader(mqtt.PacketPublish, flags, uint32(vp.Size(mqtt.QoS0)+len(payload)))
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i, subs := range b.subs {
+		for _, s := range subs {
+			if s == topic {
+				b.txs[i].WritePublishPayload(hdr, vp, payload)
+				break
+			}
+		}
+	}
+}
+
+// serve speaks just enough MQTT to one client. The client's will is
+// published if the connection ends without a DISCONNECT.
+func (b *Broker) serve(i int, conn net.Conn, tx *mqtt.Tx) {
+	var will Message
+	defer func() {
+		conn.Close()
+		if will.Topic != "" {
+			b.publish(will)
+		}
+	}()
+	dec := mqtt.DecoderNoAlloc{UserBuffer: make([]byte, 1500)}
+	for {
+		hdr, _, err := mqtt.DecodeHeader(conn)
+		if err != nil {
+			return
+		}
+		switch hdr.Type() {
+		case mqtt.PacketConnect:
+			var vc mqtt.VariablesConnect
+			vc, _, err = dec.DecodeConnect(conn)
+			if err == nil {
+				if vc.WillFlag() {
+					will = Message{
+						Topic:   string(vc.WillTopic),
+						Payload: append([]byte(nil), vc.WillMessage...),
+						Retain:  vc.WillRetain,
+					}
+				}
+				b.mu.Lock()
+				err = tx.WriteConnack(mqtt.VariablesConnack{})
+				b.mu.Unlock()
+			}
+		case mqtt.PacketSubscribe:
+			var sub mqtt.VariablesSubscribe
+			sub, _, err = dec.DecodeSubscribe(conn, hdr.RemainingLength)
+			if err != nil {
+				break
+			}
+			ack := mqtt.VariablesSuback{PacketIdentifier: sub.PacketIdentifier}
+			b.mu.Lock()
+			for _, f := range sub.TopicFilters {
+				b.subs[i] = append(b.subs[i], string(f.TopicFilter))
+				ack.ReturnCodes = append(ack.ReturnCodes, mqtt.QoS0)
+			}
+			err = tx.WriteSuback(ack)
+			b.mu.Unlock()
+		case mqtt.PacketPublish:
+			var vp mqtt.VariablesPublish
+			var n int
+			vp, n, err = dec.DecodePublish(conn, hdr.Flags().QoS())
+			if err != nil {
+				break
+			}
+			payload := make([]byte, int(hdr.RemainingLength)-n)
+			if _, err = io.ReadFull(conn, payload); err != nil {
+				break
+			}
+			b.publish(Message{Topic: string(vp.TopicName), Payload: payload, Retain: hdr.Flags().Retain()})
+		case mqtt.PacketPingreq:
+			b.mu.Lock()
+			if !b.mute {
+				err = tx.WriteSimple(mqtt.PacketPingresp)
+			}
+			b.mu.Unlock()
+		case mqtt.PacketDisconnect:
+			will = Message{}
+			return
+		default:
+			err = errors.New("link: broker does not handle " + hdr.Type().String())
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// FakeDialer dials a Broker.
+type FakeDialer struct {
+	Broker *Broker
+	Config Config
+
+	mu         sync.Mutex
+	networks   int
+	networkErr error
+	dialErr    error
+}
+
+// Fail makes joining the network and dialing the broker return these
+// errors, nil lets them work again.
+func (d *FakeDialer) Fail(network, dial error) {
+	d.mu.Lock()
+	d.networkErr, d.dialErr = network, dial
+	d.mu.Unlock()
+}
+
+func (d *FakeDialer) Network() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.networks++
+	return d.networkErr
+}
+
+// Networks counts how many times the network was joined.
+func (d *FakeDialer) Networks() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.networks
+}
+
+func (d *FakeDialer) Dial() (Session, error) {
+	d.mu.Lock()
+	err := d.dialErr
+	d.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	m, err := DialMQTT(d.Broker.Conn(), &d.Config)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
@@ -0,0 +1,256 @@
+// Package link keeps the headset connected to the network and the MQTT
+// broker. Connecting is slow and can block for seconds, so it happens in the
+// background while the game loop only looks at the state.
+package link
+
+import (
+	"errors"
+	"time"
+)
+
+// State of the link.
+type State uint8
+
+const (
+	// Off means the supervisor has not been started or was stopped.
+	Off State = iota
+	// Waiting means the last attempt failed and the next one is backing off.
+	Waiting
+	// Connecting means an attempt is running in the background.
+	Connecting
+	// Up means there is a working session with the broker.
+	Up
+)
+
+func (s State) String() string {
+	switch s {
+	case Waiting:
+		return "WAIT"
+	case Connecting:
+		return "CONNECTING"
+	case Up:
+		return "UP"
+	}
+	return "OFF"
+}
+
+var (
+	ErrDown        = errors.New("link: not connected")
+	ErrPingTimeout = errors.New("link: no answer to ping")
+	ErrClosed      = errors.New("link: session lost")
+)
+
+// Message is something to publish.
+type Message struct {
+	Topic   string
+	Payload []byte
+	// Retain asks the broker to keep the message for whoever subscribes
+	// later.
+	Retain bool
+}
+
+// Session is a connection to the broker.
+type Session interface {
+	// Connected reports whether the session is still alive.
+	Connected() bool
+	// Publish sends payload on topic, retained or not.
+	Publish(topic string, payload []byte, retain bool) error
+	// Ping sends a keepalive without waiting for the answer.
+	Ping() error
+	// AwaitingPing reports whether the last ping is still unanswered.
+	AwaitingPing() bool
+	Close()
+}
+
+// Dialer brings the link up. Both calls may block.
+type Dialer interface {
+	// Network joins the network, it is only called again after a Dial
+	// failed.
+	Network() error
+	// Dial opens a session with the broker, subscribed to everything it
+	// needs.
+	Dial() (Session, error)
+}
+
+// Supervisor connects in the background, watches the session and
+// reconnects when it breaks, backing off between attempts. It is driven by
+// calling Update once per frame, which never blocks.
+type Supervisor struct {
+	Dialer  Dialer
+	Backoff Backoff
+	// KeepAlive is how often an idle session is pinged.
+	KeepAlive time.Duration
+	// PingTimeout is how long to wait for the answer before the session is
+	// given up.
+	PingTimeout time.Duration
+
+	// Drops counts sessions that broke, Attempts every connection attempt.
+	Drops    uint32
+	Attempts uint32
+
+	state     State
+	session   Session
+	result    chan dialResult
+	network   bool
+	wait      time.Duration
+	sincePing time.Duration
+	pingWait  time.Duration
+	err       error
+}
+
+type dialResult struct {
+	session Session
+	err     error
+	network bool
+}
+
+// State reports the current state of the link.
+func (s *Supervisor) State() State {
+	return s.state
+}
+
+// Joined reports whether the network is joined, as far as the last attempt
+// knows.
+func (s *Supervisor) Joined() bool {
+	return s.network
+}
+
+// Err is the reason the last session or attempt failed.
+func (s *Supervisor) Err() error {
+	return s.err
+}
+
+// Start brings the link up, if it is not already.
+func (s *Supervisor) Start() {
+	if s.state != Off {
+		return
+	}
+	s.Backoff.Reset()
+	s.dial()
+}
+
+// Stop drops the session and stops reconnecting.
+func (s *Supervisor) Stop() {
+	switch s.state {
+	case Up:
+		s.session.Close()
+		s.session = nil
+	case Connecting:
+		// nobody is going to take this session, close it when it arrives
+		go func(result chan dialResult) {
+			if r := <-result; r.err == nil {
+				r.session.Close()
+			}
+		}(s.result)
+	}
+	s.result = nil
+	s.network = false
+	s.state = Off
+}
+
+// Update advances the supervisor by dt and returns the resulting state.
+func (s *Supervisor) Update(dt time.Duration) State {
+	switch s.state {
+	case Waiting:
+		s.wait -= dt
+		if s.wait <= 0 {
+			s.dial()
+		}
+	case Connecting:
+		select {
+		case r := <-s.result:
+			s.network = r.network
+			if r.err != nil {
+				s.fail(r.err)
+				break
+			}
+			s.session = r.session
+			s.sincePing, s.pingWait = 0, 0
+			s.err = nil
+			s.Backoff.Reset()
+			s.state = Up
+		default:
+		}
+	case Up:
+		s.watch(dt)
+	}
+	return s.state
+}
+
+// watch checks a live session and keeps it alive.
+func (s *Supervisor) watch(dt time.Duration) {
+	if !s.session.Connected() {
+		s.drop(ErrClosed)
+		return
+	}
+	if s.session.AwaitingPing() {
+		s.pingWait += dt
+		if s.PingTimeout > 0 && s.pingWait > s.PingTimeout {
+			s.drop(ErrPingTimeout)
+		}
+		return
+	}
+	s.pingWait = 0
+	s.sincePing += dt
+	if s.KeepAlive > 0 && s.sincePing >= s.KeepAlive {
+		s.sincePing = 0
+		if err := s.session.Ping(); err != nil {
+			s.drop(err)
+		}
+	}
+}
+
+// Publish sends payload on topic if the link is up. A failed publish drops
+// the session so it gets reconnected.
+func (s *Supervisor) Publish(topic string, payload []byte) error {
+	return s.publish(topic, payload, false)
+}
+
+// Retain is Publish for a message the broker keeps.
+func (s *Supervisor) Retain(topic string, payload []byte) error {
+	return s.publish(topic, payload, true)
+}
+
+func (s *Supervisor) publish(topic string, payload []byte, retain bool) error {
+	if s.state != Up {
+		return ErrDown
+	}
+	if err := s.session.Publish(topic, payload, retain); err != nil {
+		s.drop(err)
+		return err
+	}
+	return nil
+}
+
+// dial starts a connection attempt in the background.
+func (s *Supervisor) dial() {
+	s.Attempts++
+	s.state = Connecting
+	s.result = make(chan dialResult, 1)
+	go func(d Dialer, network bool, result chan dialResult) {
+		if !network {
+			if err := d.Network(); err != nil {
+				result <- dialResult{err: err}
+				return
+			}
+		}
+		session, err := d.Dial()
+		// a broker that cannot be reached may mean the network is gone
+		result <- dialResult{session: session, err: err, network: err == nil}
+	}(s.Dialer, s.network, s.result)
+}
+
+// drop gives up a session that was up.
+func (s *Supervisor) drop(err error) {
+	s.Drops++
+	s.session.Close()
+	s.session = nil
+	s.fail(err)
+}
+
+// fail schedules the next attempt.
+func (s *Supervisor) fail(err error) {
+	s.err = err
+	s.wait = s.Backoff.Next()
+	s.state = Waiting
+}
@@ -0,0 +1,156 @@
+package link
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+const step = time.Millisecond
+
+var (
+	online  = Message{Topic: "vision/test/status", Payload: []byte("online"), Retain: true}
+	offline = Message{Topic: "vision/test/status", Payload: []byte("offline"), Retain: true}
+)
+
+// fakeLink returns a supervisor for a fresh broker, with short waits so the
+// tests do not take long.
+func fakeLink() (*Supervisor, *FakeDialer, *Broker) {
+	b := &Broker{}
+	d := &FakeDialer{Broker: b, Config: Config{
+		ClientID:      "test",
+		Timeout:       time.Second,
+		Subscriptions: []string{"vision/test/cmd"},
+		Will:          offline,
+		Announce:      []Message{online},
+	}}
+	s := &Supervisor{
+		Dialer:      d,
+		Backoff:     Backoff{Min: 5 * time.Millisecond, Max: 20 * time.Millisecond},
+		KeepAlive:   10 * time.Millisecond,
+		PingTimeout: 30 * time.Millisecond,
+	}
+	return s, d, b
+}
+
+// until runs s, a frame per millisecond, until it gets to want.
+func until(t *testing.T, s *Supervisor, want State) {
+	t.Helper()
+	for i := 0; i < 2000; i++ {
+		if s.Update(step) == want {
+			return
+		}
+		time.Sleep(step)
+	}
+	t.Fatalf("still %v, want %v, last error %v", s.State(), want, s.Err())
+}
+
+// retained fails unless the broker keeps want for its topic.
+func retained(t *testing.T, b *Broker, want Message) {
+	t.Helper()
+	got, ok := b.Retained(want.Topic)
+	if !ok || string(got.Payload) != string(want.Payload) {
+		t.Errorf("retained %q on %s, want %q", got.Payload, want.Topic, want.Payload)
+	}
+}
+
+func TestReconnectAfterDrop(t *testing.T) {
+	s, d, b := fakeLink()
+	defer s.Stop()
+	s.Start()
+	until(t, s, Up)
+	retained(t, b, online)
+
+	b.Drop()
+	until(t, s, Waiting)
+	if s.Err() != ErrClosed {
+		t.Errorf("dropped with %v, want %v", s.Err(), ErrClosed)
+	}
+	until(t, s, Up)
+	if s.Drops != 1 || s.Attempts != 2 {
+		t.Errorf("%d drops in %d attempts, want 1 in 2", s.Drops, s.Attempts)
+	}
+	// the network was still there, only the broker went away
+	if n := d.Networks(); n != 1 {
+		t.Errorf("joined the network %d times, want 1", n)
+	}
+	retained(t, b, online)
+}
+
+func TestReconnectAfterMute(t *testing.T) {
+	s, _, b := fakeLink()
+	defer s.Stop()
+	s.Start()
+	until(t, s, Up)
+
+	// a broker that stops answering pings is given up
+	b.Mute(true)
+	until(t, s, Waiting)
+	if s.Err() != ErrPingTimeout {
+		t.Errorf("dropped with %v, want %v", s.Err(), ErrPingTimeout)
+	}
+
+	b.Mute(false)
+	until(t, s, Up)
+	if s.Drops != 1 {
+		t.Errorf("%d drops, want 1", s.Drops)
+	}
+	// and the session stays up while it answers
+	for i := 0; i < 100; i++ {
+		if s.Update(step) != Up {
+			t.Fatalf("dropped again with %v", s.Err())
+		}
+		time.Sleep(step)
+	}
+}
+
+func TestWillIsRetained(t *testing.T) {
+	s, _, b := fakeLink()
+	s.Start()
+	until(t, s, Up)
+	retained(t, b, online)
+
+	// the broker publishes the will of a connection that died
+	b.Drop()
+	until(t, s, Waiting)
+	retained(t, b, offline)
+
+	// and the headset publishes it on its way out
+	until(t, s, Up)
+	retained(t, b, online)
+	s.Stop()
+	retained(t, b, offline)
+}
+
+func TestFailedAttemptsBackOff(t *testing.T) {
+	s, d, _ := fakeLink()
+	defer s.Stop()
+	noWifi := errors.New("no wifi")
+	d.Fail(noWifi, nil)
+	s.Start()
+	until(t, s, Waiting)
+	if s.Err() != noWifi {
+		t.Errorf("failed with %v, want %v", s.Err(), noWifi)
+	}
+	if s.Joined() {
+		t.Error("joined a network that failed")
+	}
+
+	// the network comes back but the broker is not there yet
+	noBroker := errors.New("no broker")
+	d.Fail(nil, noBroker)
+	until(t, s, Connecting)
+	until(t, s, Waiting)
+	if s.Err() != noBroker {
+		t.Errorf("failed with %v, want %v", s.Err(), noBroker)
+	}
+
+	d.Fail(nil, nil)
+	until(t, s, Up)
+	if !s.Joined() {
+		t.Error("up but not joined")
+	}
+	if s.Err() != nil {
+		t.Errorf("up with error %v", s.Err())
+	}
+}
@@ -0,0 +1,161 @@
+package link
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	mqtt "github.com/soypat/natiu-mqtt"
+)
+
+// Config of an MQTT session.
+type Config struct {
+	ClientID string
+	User     string
+	Password string
+	// KeepAlive is sent to the broker, in seconds.
+	KeepAlive uint16
+	// Timeout bounds the CONNECT and SUBSCRIBE handshakes.
+	Timeout time.Duration
+	// Subscriptions are subscribed to on every new session.
+	Subscriptions []string
+	// Will is published by the broker if the session dies without saying
+	// goodbye. Closing the session publishes it too, so it always ends up
+	// on the broker when the session is gone.
+	Will Message
+	// Announce is published, in order, right after every new session
+	// starts.
+	Announce []Message
+	// OnMessage is called from the session's reader goroutine for every
+	// message received, payload is only valid during the call.
+	OnMessage func(topic string, payload []byte)
+}
+
+// MQTT is a Session over natiu-mqtt. A goroutine reads from the broker for
+// as long as the session lives, so pings are answered and messages arrive
+// without the game loop waiting on the socket.
+type MQTT struct {
+	cfg     *Config
+	cl      *mqtt.Client
+	conn    io.ReadWriteCloser
+	pubVar  mqtt.VariablesPublish
+	payload []byte
+	pingAt  time.Time
+}
+
+// DialMQTT runs the MQTT handshake over conn and subscribes to every topic
+// in cfg. conn is closed if it fails.
+func DialMQTT(conn io.ReadWriteCloser, cfg *Config) (*MQTT, error) {
+	m := &MQTT{cfg: cfg, conn: conn, payload: make([]byte, 512)}
+	m.cl = mqtt.NewClient(mqtt.ClientConfig{
+		Decoder: mqtt.DecoderNoAlloc{UserBuffer: make([]byte, 1500)},
+		OnPub: func(_ mqtt.Header, varPub mqtt.VariablesPublish, r io.Reader) error {
+			n, _ := io.ReadFull(r, m.payload)
+			// drop whatever does not fit
+			io.Copy(io.Discard, r)
+			if cfg.OnMessage != nil {
+				cfg.OnMessage(string(varPub.TopicName), m.payload[:n])
+			}
+			return nil
+		},
+	})
+
+	timeout := cfg.Timeout
+	if timeout == 0 {
+		timeout = 10 * time.Second
+	}
+
+	var varconn mqtt.VariablesConnect
+	varconn.KeepAlive = cfg.KeepAlive
+	varconn.SetDefaultMQTT([]byte(cfg.ClientID))
+	if cfg.User != "" {
+		varconn.Username = []byte(cfg.User)
+		varconn.Password = []byte(cfg.Password)
+	}
+	if cfg.Will.Topic != "" {
+		varconn.WillTopic = []byte(cfg.Will.Topic)
+		varconn.WillMessage = cfg.Will.Payload
+		varconn.WillRetain = cfg.Will.Retain
+		varconn.WillQoS = mqtt.QoS0
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	err := m.cl.Connect(ctx, conn, &varconn)
+	cancel()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if len(cfg.Subscriptions) > 0 {
+		sub := mqtt.VariablesSubscribe{PacketIdentifier: 1}
+		for _, topic := range cfg.Subscriptions {
+			sub.TopicFilters = append(sub.TopicFilters, mqtt.SubscribeRequest{
+				TopicFilter: []byte(topic), QoS: mqtt.QoS0,
+			})
+		}
+		ctx, cancel = context.WithTimeout(context.Background(), timeout)
+		err = m.cl.Subscribe(ctx, sub)
+		cancel()
+		if err == nil && len(m.cl.SubscribedTopics()) != len(cfg.Subscriptions) {
+			err = errors.New("link: subscription refused")
+		}
+		if err != nil {
+			m.Close()
+			return nil, err
+		}
+	}
+
+	for _, msg := range cfg.Announce {
+		if err = m.Publish(msg.Topic, msg.Payload, msg.Retain); err != nil {
+			m.Close()
+			return nil, err
+		}
+	}
+
+	go m.read()
+	return m, nil
+}
+
+// read handles everything the broker sends until the session breaks.
+func (m *MQTT) read() {
+	for m.cl.IsConnected() {
+		if err := m.cl.HandleNext(); err != nil {
+			m.cl.Disconnect(err)
+			return
+		}
+	}
+}
+
+func (m *MQTT) Connected() bool {
+	return m.cl.IsConnected()
+}
+
+func (m *MQTT) Publish(topic string, payload []byte, retain bool) error {
+	flags, _ := mqtt.NewPublishFlags(mqtt.QoS0, false, retain)
+	m.pubVar.TopicName = []byte(topic)
+	m.pubVar.PacketIdentifier++
+	return m.cl.PublishPayload(flags, m.pubVar, payload)
+}
+
+func (m *MQTT) Ping() error {
+	m.pingAt = time.Now()
+	return m.cl.StartPing()
+}
+
+// AwaitingPing also looks at when the broker last sent something: the
+// client only flags the ping once it is written, so a quick enough answer
+// is read before the flag is set and would never clear it.
+func (m *MQTT) AwaitingPing() bool {
+	return m.cl.AwaitingPingresp() && !m.cl.LastRx().After(m.pingAt)
+}
+
+// Close ends the session, publishing the will first. The transport is
+// closed before disconnecting so the reader goroutine lets go of the client.
+func (m *MQTT) Close() {
+	if will := m.cfg.Will; will.Topic != "" && m.cl.IsConnected() {
+		m.Publish(will.Topic, will.Payload, will.Retain)
+	}
+	m.conn.Close()
+	m.cl.Disconnect(ErrClosed)
+}
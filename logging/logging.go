@@ -0,0 +1,266 @@
+// Package logging is a small leveled logger for the firmware. Every entry
+// has a level and the subsystem it comes from, it is kept in a ring buffer
+// for whoever wants to read it later and written to an optional sink as it
+// happens. Logging does not allocate: entries are fixed size and the text
+// is copied in, cut if it is too long.
+package logging
+
+import (
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Level of an entry, entries below the level of the logger are dropped.
+type Level uint8
+
+const (
+	Debug Level = iota
+	Info
+	Warn
+	Error
+	// Off is above every level, it drops everything.
+	Off
+)
+
+var levelNames = [...]string{
+	Debug: "DEBUG",
+	Info:  "INFO",
+	Warn:  "WARN",
+	Error: "ERROR",
+	Off:   "OFF",
+}
+
+func (l Level) String() string {
+	if int(l) < len(levelNames) {
+		return levelNames[l]
+	}
+	return "?"
+}
+
+// ParseLevel reads a level by name, case does not matter.
+func ParseLevel(s string) (Level, bool) {
+	for l, name := range levelNames {
+		if strings.EqualFold(s, name) {
+			return Level(l), true
+		}
+	}
+	return 0, false
+}
+
+const (
+	// TextSize is how much text an entry keeps.
+	TextSize = 72
+	// RingSize is how many entries the ring buffer keeps.
+	RingSize = 32
+	// MaxTags is how many subsystems can have a level of their own.
+	MaxTags = 16
+)
+
+// Entry is one logged line.
+type Entry struct {
+	// Seq counts the entries of the logger, from 0.
+	Seq uint32
+	// Time is since the first entry.
+	Time  time.Duration
+	Level Level
+	Tag   string
+	n     uint8
+	text  [TextSize]byte
+}
+
+// Text is what was logged.
+func (e *Entry) Text() []byte {
+	return e.text[:e.n]
+}
+
+// Append appends the entry to b as one line of text, without the line end:
+// the seconds since the first entry, the first letter of the level, the tag
+// and the text.
+func (e *Entry) Append(b []byte) []byte {
+	ms := e.Time / time.Millisecond
+	b = strconv.AppendInt(b, int64(ms/1000), 10)
+	b = append(b, '.')
+	frac := ms % 1000
+	if frac < 100 {
+		b = append(b, '0')
+	}
+	if frac < 10 {
+		b = append(b, '0')
+	}
+	b = strconv.AppendInt(b, int64(frac), 10)
+	b = append(b, ' ', e.Level.String()[0], ' ')
+	b = append(b, e.Tag...)
+	b = append(b, ' ')
+	return append(b, e.Text()...)
+}
+
+// Logger keeps the entries and writes them to Out. It is safe for
+// concurrent use, once in use Level and Out are changed with SetLevel and
+// SetOut.
+type Logger struct {
+	// Level is the lowest level logged, for the subsystems without one of
+	// their own.
+	Level Level
+	// Out is the sink, every entry is written there as a line. Nil writes
+	// nothing, the entries are still kept. Writing must not log.
+	Out io.Writer
+
+	mu    sync.Mutex
+	start time.Time
+	seq   uint32
+	ring  [RingSize]Entry
+	tags  [MaxTags]tagLevel
+	ntags int
+	line  [TextSize + 32]byte
+}
+
+type tagLevel struct {
+	tag   string
+	level Level
+}
+
+// SetLevel changes Level.
+func (l *Logger) SetLevel(level Level) {
+	l.mu.Lock()
+	l.Level = level
+	l.mu.Unlock()
+}
+
+// CurrentLevel returns Level, for reading it while the logger is in use.
+func (l *Logger) CurrentLevel() Level {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.Level
+}
+
+// SetOut changes Out.
+func (l *Logger) SetOut(w io.Writer) {
+	l.mu.Lock()
+	l.Out = w
+	l.mu.Unlock()
+}
+
+// SetTagLevel gives the subsystem tag a level of its own, it reports false
+// when there is no room for another one.
+func (l *Logger) SetTagLevel(tag string, level Level) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i := range l.tags[:l.ntags] {
+		if l.tags[i].tag == tag {
+			l.tags[i].level = level
+			return true
+		}
+	}
+	if l.ntags == len(l.tags) {
+		return false
+	}
+	l.tags[l.ntags] = tagLevel{tag, level}
+	l.ntags++
+	return true
+}
+
+// ClearTagLevels makes every subsystem use Level again.
+func (l *Logger) ClearTagLevels() {
+	l.mu.Lock()
+	l.ntags = 0
+	l.mu.Unlock()
+}
+
+// Enabled reports whether an entry of level from tag would be logged, to
+// skip building one that would not.
+func (l *Logger) Enabled(level Level, tag string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.enabled(level, tag)
+}
+
+func (l *Logger) enabled(level Level, tag string) bool {
+	if level >= Off {
+		return false
+	}
+	for _, t := range l.tags[:l.ntags] {
+		if t.tag == tag {
+			return level >= t.level
+		}
+	}
+	return level >= l.Level
+}
+
+// Log logs words separated by spaces, from the subsystem tag.
+func (l *Logger) Log(level Level, tag string, words ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.enabled(level, tag) {
+		return
+	}
+	now := time.Now()
+	if l.seq == 0 {
+		l.start = now
+	}
+	e := &l.ring[l.seq%RingSize]
+	e.Seq = l.seq
+	e.Time = now.Sub(l.start)
+	e.Level = level
+	e.Tag = tag
+	n := 0
+	for i, w := range words {
+		if i > 0 && n < TextSize {
+			e.text[n] = ' '
+			n++
+		}
+		n += copy(e.text[n:], w)
+	}
+	e.n = uint8(n)
+	l.seq++
+
+	if l.Out != nil {
+		line := e.Append(l.line[:0])
+		l.Out.Write(append(line, '\r', '\n'))
+	}
+}
+
+// Seq is the Seq the next entry will have.
+func (l *Logger) Seq() uint32 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.seq
+}
+
+// Read copies into e the first entry still kept whose Seq is seq or later,
+// it reports false if there is none yet.
+func (l *Logger) Read(seq uint32, e *Entry) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if seq >= l.seq {
+		return false
+	}
+	if l.seq-seq > RingSize {
+		seq = l.seq - RingSize
+	}
+	*e = l.ring[seq%RingSize]
+	return true
+}
+
+// Tag returns a logger for the subsystem tag.
+func (l *Logger) Tag(tag string) Tagged {
+	return Tagged{l, tag}
+}
+
+// Tagged logs for one subsystem.
+type Tagged struct {
+	L   *Logger
+	Tag string
+}
+
+// Enabled reports whether an entry of level would be logged.
+func (t Tagged) Enabled(level Level) bool {
+	return t.L.Enabled(level, t.Tag)
+}
+
+func (t Tagged) Debug(words ...string) { t.L.Log(Debug, t.Tag, words...) }
+func (t Tagged) Info(words ...string)  { t.L.Log(Info, t.Tag, words...) }
+func (t Tagged) Warn(words ...string)  { t.L.Log(Warn, t.Tag, words...) }
+func (t Tagged) Error(words ...string) { t.L.Log(Error, t.Tag, words...) }
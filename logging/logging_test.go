@@ -0,0 +1,83 @@
+package logging
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestEnabled(t *testing.T) {
+	var l Logger
+	l.SetLevel(Warn)
+	l.SetTagLevel("maze", Debug)
+	for _, tt := range []struct {
+		level Level
+		tag   string
+		want  bool
+	}{
+		{Info, "wifi", false},
+		{Warn, "wifi", true},
+		{Debug, "maze", true},
+		{Off, "maze", false},
+	} {
+		if got := l.Enabled(tt.level, tt.tag); got != tt.want {
+			t.Errorf("Enabled(%v, %s) = %v, want %v", tt.level, tt.tag, got, tt.want)
+		}
+	}
+	l.ClearTagLevels()
+	if l.Enabled(Debug, "maze") {
+		t.Error("tag level kept after ClearTagLevels")
+	}
+}
+
+func TestLogAndRead(t *testing.T) {
+	var out strings.Builder
+	l := Logger{Level: Info, Out: &out}
+	l.Log(Debug, "maze", "dropped")
+	l.Log(Info, "wifi", "joining", "home")
+	l.Log(Warn, "wifi", strings.Repeat("x", TextSize+10))
+
+	var e Entry
+	if !l.Read(0, &e) || e.Seq != 0 || e.Tag != "wifi" || string(e.Text()) != "joining home" {
+		t.Errorf("first entry %d %s %q", e.Seq, e.Tag, e.Text())
+	}
+	if !l.Read(1, &e) || len(e.Text()) != TextSize {
+		t.Errorf("long entry kept %d bytes, want %d", len(e.Text()), TextSize)
+	}
+	if l.Read(2, &e) {
+		t.Error("read past the last entry")
+	}
+	if !strings.Contains(out.String(), " I wifi joining home\r\n") {
+		t.Errorf("wrote %q", out.String())
+	}
+}
+
+func TestRingKeepsTheLatest(t *testing.T) {
+	var l Logger
+	for i := 0; i < RingSize+5; i++ {
+		l.Log(Info, "t", "entry")
+	}
+	var e Entry
+	if !l.Read(0, &e) || e.Seq != 5 {
+		t.Errorf("oldest kept is %d, want 5", e.Seq)
+	}
+}
+
+func TestLevelWhileLogging(t *testing.T) {
+	var l Logger
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			l.SetLevel(Level(i % 4))
+		}
+	}()
+	for i := 0; i < 1000; i++ {
+		if level := l.CurrentLevel(); level >= Off {
+			t.Fatalf("read level %v", level)
+		}
+		l.Log(Info, "t", "entry")
+	}
+	wg.Wait()
+}
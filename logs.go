@@ -0,0 +1,88 @@
+package main
+
+import (
+	"io"
+
+	"github.com/conejoninja/vision/link"
+	"github.com/conejoninja/vision/logging"
+)
+
+// logger keeps the last entries and sends them to the sink picked with
+// setLogSink. Remote and console commands change the levels, LOG DEBUG LINK
+// shows everything about the link.
+var logger = logging.Logger{Level: logging.Info}
+
+// The subsystems that log.
+var (
+	boardLog    = logger.Tag("BOARD")
+	loopLog     = logger.Tag("LOOP")
+	linkLog     = logger.Tag("LINK")
+	mqttLog     = logger.Tag("MQTT")
+	commandLog  = logger.Tag("COMMAND")
+	raceLog     = logger.Tag("RACE")
+	settingsLog = logger.Tag("SETTINGS")
+	calibLog    = logger.Tag("CALIB")
+	gameLog     = logger.Tag("GAME")
+	mazeLog     = logger.Tag("MAZE")
+)
+
+// Where the log goes.
+const (
+	logSerial = iota
+	logMQTT
+	logNone
+)
+
+var logSinkNames = [...]string{
+	logSerial: "SERIAL",
+	logMQTT:   "MQTT",
+	logNone:   "NONE",
+}
+
+var (
+	logSink = logSerial
+	// serialLog is the serial port, set by the board.
+	serialLog io.Writer
+	// logSeq is the next entry to publish when the log goes to MQTT.
+	logSeq     uint32
+	logPayload []byte
+)
+
+// logBudget is how many entries go to MQTT in a frame at most, the rest
+// wait in the ring buffer.
+const logBudget = 4
+
+// setLogSink sends the log to sink. Switching to MQTT sends what the ring
+// buffer still has first.
+func setLogSink(sink int) {
+	logSink = sink
+	if sink == logSerial {
+		logger.SetOut(serialLog)
+	} else {
+		logger.SetOut(nil)
+	}
+	logSeq = 0
+}
+
+// logSinkByName finds a sink by the name LOG SINK takes.
+func logSinkByName(name string) (int, bool) {
+	for i, n := range logSinkNames {
+		if n == name {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// flushLog publishes the new entries on logTopic when the log goes to MQTT.
+func flushLog() {
+	if logSink != logMQTT || network.State() != link.Up {
+		return
+	}
+	var e logging.Entry
+	for i := 0; i < logBudget && logger.Read(logSeq, &e); i++ {
+		logSeq = e.Seq + 1
+		logPayload = e.Append(logPayload[:0])
+		publishNow(logTopic, &logPayload)
+	}
+}
@@ -1,25 +1,27 @@
 package main
 
 import (
-	"machine"
 	"math"
 	"strconv"
 
 	"image/color"
 	"time"
 
-	"tinygo.org/x/drivers"
-	"tinygo.org/x/drivers/lsm303agr"
-	"tinygo.org/x/drivers/ssd1306"
-	"tinygo.org/x/drivers/ws2812"
+	"github.com/conejoninja/vision/controls"
+	"github.com/conejoninja/vision/hal"
+	"github.com/conejoninja/vision/logging"
+	"github.com/conejoninja/vision/timing"
 	"tinygo.org/x/tinyfont"
 )
 
 const (
-	NUMLEDS = 44 // Adjust this to match your LED strip
-	SPEED   = 16
+	NUMLEDS = 44  // Adjust this to match your LED strip
+	SPEED   = 320 // maze units per second
 )
 
+// frameTime is the fixed step every game update simulates.
+const frameTime = 50 * time.Millisecond
+
 const (
 	MID = iota
 	RIGHT
@@ -32,13 +34,9 @@ const (
 const (
 	IDLE = iota
 	CENTERING
-)
-
-const (
-	NORTH = iota
-	CIRCLE
-	MAZE
-	GAMEOVER
+	CALIBRATING
+	STATS
+	MENU
 )
 
 const (
@@ -49,21 +47,35 @@ const (
 	BLUE
 )
 
-const useWifi = false
+var (
+	// useWifi and useMQTT say whether to connect at all, they are changed
+	// from the menu and kept in the settings.
+	useWifi = false
+	useMQTT = true
+)
 
 var (
-	neo                                            machine.Pin = machine.A1
-	jx                                             machine.ADC
-	jy                                             machine.ADC
-	leds                                           [NUMLEDS]color.RGBA
-	ledBytes                                       []byte
-	display                                        ssd1306.Device
-	data                                           []byte
-	circleArc, circleOrientation                   byte
-	ledIndex, heading, offsetHeading, circleRadius int
-	headingRads, offsetHeadingRads                 float64
-	mode                                           = IDLE
-	game                                           = MAZE
+	display  hal.Display
+	sensor   hal.Magnetometer
+	accel    hal.Accelerometer
+	strip    hal.LEDStrip
+	buttons  hal.Buttons
+	joystick hal.Joystick
+	stick    = controls.Stick{Deadzone: 0.15, InvertX: true, InvertY: true}
+
+	leds                             [NUMLEDS]color.RGBA
+	ledBytes                         []byte
+	data                             []byte
+	ledIndex, heading, offsetHeading int
+	headingRads, offsetHeadingRads   float64
+	mode                             = IDLE
+	clock                            = timing.Clock{Step: frameTime, MaxSteps: 4}
+	game                             = MAZE
+	input                            Input
+
+	// bouncing pixel shown on the OLED while IDLE
+	x, y           int16
+	deltaX, deltaY int16 = 1, 1
 
 	colors = []color.RGBA{
 		color.RGBA{255, 255, 255, 255},
@@ -73,337 +85,235 @@ var (
 		color.RGBA{0, 0, 255, 255},
 	}
 
-	gpioPins = []machine.Pin{
-		machine.GPIO1,
-		machine.GPIO0,
-		machine.GPIO16,
-		machine.GPIO15,
-		machine.GPIO25,
-		machine.GPIO26,
+	// buttonEvents turns the buttons into presses, long presses, double
+	// clicks, repeats and combos, events holds what happened this frame and
+	// pressedBtn the buttons that went down.
+	buttonEvents = controls.Buttons{
+		Debounce:    20 * time.Millisecond,
+		LongPress:   800 * time.Millisecond,
+		DoubleClick: 300 * time.Millisecond,
+		RepeatDelay: 500 * time.Millisecond,
+		RepeatRate:  150 * time.Millisecond,
+		Combos:      []uint32{calibrationMask},
 	}
-	debounceBtn [6]bool
-	pressedBtn  [6]bool
+	events     []controls.Event
+	pressedBtn [6]bool
 )
 
 func main() {
-	//waitSerial()
+	err := setupBoard()
+	setLogSink(logSink)
+	if err != nil {
+		failed(err)
+	}
 
-	machine.InitADC()
+	ledBytes = make([]byte, NUMLEDS*3)
 
-	machine.I2C0.Configure(machine.I2CConfig{
-		Frequency: machine.TWI_FREQ_400KHZ,
-	})
+	// the stick is at rest at boot, take that as its centre
+	stick.Joystick = joystick
+	stick.Calibrate()
+	buttonEvents.Buttons = buttons
 
-	display := ssd1306.NewI2C(machine.I2C0)
-	display.Configure(ssd1306.Config{
-		Address:  0x3C,
-		Width:    128,
-		Height:   64,
-		Rotation: drivers.Rotation180,
-	})
+	loadSettings()
 
-	display.ClearDisplay()
+	// the network comes up in the background while playing
+	connect()
 
-	_, w := tinyfont.LineWidth(&tinyfont.Org01, "BOOT UP...")
-	tinyfont.WriteLine(display, &tinyfont.Org01, int16(128-w)/2, 40, "BOOT UP...", colors[WHITE])
-	display.Display()
+	switchGame(game)
 
-	sensor := lsm303agr.New(machine.I2C0)
-	err := sensor.Configure(lsm303agr.Configuration{}) //default settings
-	if err != nil {
-		display.ClearDisplay()
-		_, w := tinyfont.LineWidth(&tinyfont.Org01, "FAILED")
-		tinyfont.WriteLine(display, &tinyfont.Org01, int16(128-w)/2, 40, "FAILED", colors[WHITE])
-		display.Display()
-		for {
-			println("Failed to configure", err.Error())
-			time.Sleep(time.Second)
+	for {
+		steps := clock.Begin(time.Now())
+		if err := loop(steps); err != nil {
+			return
 		}
-	}
 
-	neo.Configure(machine.PinConfig{Mode: machine.PinOutput})
-
-	ws := ws2812.NewWS2812(neo)
+		time.Sleep(clock.End(time.Now()))
+	}
+}
 
-	for c := range gpioPins {
-		gpioPins[c].Configure(machine.PinConfig{Mode: machine.PinInputPullup})
+// loop runs a single frame: it reads the inputs, updates the current game by
+// the given number of steps, lights the LEDs and refreshes the OLED.
+func loop(steps int) error {
+	// the mode the frame started in owns the buttons, finishing
+	// calibration with MID must not reach the game as well
+	frameMode := mode
+
+	pollConsole()
+	updateNetwork(time.Duration(steps) * frameTime)
+	runCommands()
+	updateRace(time.Duration(steps) * frameTime)
+
+	events = buttonEvents.Update(time.Duration(steps) * frameTime)
+	pressedBtn = [6]bool{}
+	for _, e := range events {
+		if e.Kind == controls.Press && e.Button < len(pressedBtn) {
+			pressedBtn[e.Button] = true
+		}
 	}
 
-	//jx := machine.ADC{machine.A2}
-	jx = machine.ADC{machine.A3}
-	jy = machine.ADC{machine.A2}
-	jx.Configure(machine.ADCConfig{})
-	jy.Configure(machine.ADCConfig{})
+	rx, ry, rz, err := readSensors(time.Duration(steps) * frameTime)
+	if err != nil {
+		return err
+	}
+	if mode == CALIBRATING {
+		updateCalibration(rx, ry, rz)
+	}
 
-	ledBytes = make([]byte, NUMLEDS*3)
+	// Calculate which LED should be lit (assuming LED 0 is at 0 degrees)
+	heading = ledHysteresis.Update((float64(NUMLEDS) * headingRads) / math.Pi)
+	if heading >= NUMLEDS {
+		heading -= 2 * NUMLEDS
+	}
+	heading = NUMLEDS - 1 - heading - (NUMLEDS / 2)
+	ledIndex = heading + offsetHeading
+	if ledIndex < 0 {
+		ledIndex += (2 * NUMLEDS)
+	}
+	ledIndex %= (2 * NUMLEDS)
+	if loopLog.Enabled(logging.Debug) {
+		loopLog.Debug("LED INDEX", strconv.Itoa(ledIndex))
+	}
 
-	display.ClearDisplay()
-	_, w = tinyfont.LineWidth(&tinyfont.Org01, "CONNECTING")
-	tinyfont.WriteLine(display, &tinyfont.Org01, int16(128-w)/2, 40, "CONNECTING", colors[WHITE])
-	display.Display()
+	input = Input{
+		Heading:           heading,
+		OffsetHeading:     offsetHeading,
+		LEDIndex:          ledIndex,
+		HeadingRads:       headingRads,
+		RawHeadingRads:    rawHeadingRads,
+		OffsetHeadingRads: offsetHeadingRads,
+		Pitch:             pitch,
+		Roll:              roll,
+		Pressed:           pressedBtn,
+		Events:            events,
+	}
+	input.JoyX, input.JoyY = joystick.Get()
+	input.StickX, input.StickY = stick.Read()
+	input.DT = frameTime
+	if frameMode != IDLE || mode != IDLE {
+		// the controls belong to the menu, calibration, centering or the
+		// stats while they are on screen
+		input.Pressed = [6]bool{}
+		input.Events = nil
+		input.StickX, input.StickY = 0, 0
+	}
 
-	connect()
+	for i := 0; i < steps; i++ {
+		games[game].Update(&input)
+		// a press only happens once, however many steps the frame runs
+		input.Pressed = [6]bool{}
+		input.Events = nil
+	}
 
-	x := int16(0)
-	y := int16(0)
-	var mx, my, mz int32
-	var xf, yf, zf, normalized float64
-	px = 720
-	py = 360
-	deltaX := int16(1)
-	deltaY := int16(1)
-	circleArc = NUMLEDS
-	circleOrientation = byte(randomInt(0, NUMLEDS*2))
-	circleRadius = 300
+	// Clear all LEDs
+	for i := range leds {
+		leds[i] = colors[BLACK]
+	}
+	current := games[game]
+	current.Render(leds[:])
+	testLEDs(time.Duration(steps) * frameTime)
+	for i := range leds {
+		ledBytes[3*i] = leds[i].R
+		ledBytes[3*i+1] = leds[i].G
+		ledBytes[3*i+2] = leds[i].B
+	}
+	applyBrightness(leds[:])
+
+	strip.WriteColors(leds[:])
+
+	// PUBLISH TO MQTT
+	publishState(current)
+	publishStats()
+	flushOutbox(time.Duration(steps) * frameTime)
+	flushLog()
+
+	switch mode {
+	case IDLE:
+		pixel := display.GetPixel(x, y)
+		c := colors[WHITE]
+		if pixel {
+			c = colors[BLACK]
+		}
+		display.SetPixel(x, y, c)
+		display.Display()
 
-	display.ClearDisplay()
-	_, w = tinyfont.LineWidth(&tinyfont.Org01, "CONNECTED")
-	tinyfont.WriteLine(display, &tinyfont.Org01, int16(128-w)/2, 40, "CONNECTED", colors[WHITE])
-	display.Display()
+		x += deltaX
+		y += deltaY
 
-	for {
-		for c := range gpioPins {
-			pressedBtn[c] = false
-			if !gpioPins[c].Get() {
-				if !debounceBtn[c] {
-					pressedBtn[c] = true
-				}
-				debounceBtn[c] = true
-			} else {
-				debounceBtn[c] = false
-			}
+		if x == 0 || x == 127 {
+			deltaX = -deltaX
 		}
 
-		my, mz, mx, err = sensor.ReadMagneticField()
-		//println(mx, my, mz)
-		if err != nil {
-			return
+		if y == 0 || y == 63 {
+			deltaY = -deltaY
 		}
-		xf = float64(mx) / 1000
-		yf = float64(my) / 1000
-		zf = float64(mz) / 1000
-		normalized = math.Sqrt(xf*xf + yf*yf + zf*zf)
-		xf = xf / normalized
-		yf = yf / normalized
-		zf = zf / normalized
-
-		phi := math.Asin(zf)
-		cosPHi := math.Cos(phi)
-
-		xf = xf * cosPHi
-		yf = yf * cosPHi
-
-		headingRads = math.Atan2(yf, xf)
-
-		// Calculate which LED should be lit (assuming LED 0 is at 0 degrees)
-		heading = int((float64(NUMLEDS) * headingRads) / math.Pi)
-		heading = NUMLEDS - 1 - heading - (NUMLEDS / 2)
-		ledIndex = heading + offsetHeading
-		if ledIndex < 0 {
-			ledIndex += (2 * NUMLEDS)
+		if pressedBtn[UP] {
+			mode = CENTERING
 		}
-		ledIndex %= (2 * NUMLEDS)
-		println("LED INDEX", ledIndex)
-
-		// Clear all LEDs
-		for i := range leds {
-			leds[i] = colors[BLACK]
-			ledBytes[3*i] = 0
-			ledBytes[3*i+1] = 0
-			ledBytes[3*i+2] = 0
+		// DOWN nudges the view, and keeps nudging while held
+		if hasEvent(controls.Press, DOWN) || hasEvent(controls.Repeat, DOWN) {
+			offsetHeadingRads++
 		}
-
-		switch game {
-		case NORTH:
-			if ledIndex >= 0 && ledIndex < NUMLEDS {
-				leds[ledIndex] = colors[RED]
-				ledBytes[3*ledIndex] = 255
-				ledBytes[3*ledIndex+1] = 0
-				ledBytes[3*ledIndex+2] = 0
-			}
-			break
-		case CIRCLE:
-
-			brightness := 300 - circleRadius
-			if brightness < 0 {
-				brightness = 0
-			} else if brightness > 255 {
-				brightness = 255
-			}
-			// gamma correction
-			brightness = int(math.Pow(float64(brightness)/255, 2.5) * 255)
-
-			c := color.RGBA{0, 0, byte(brightness), 255}
-			success := true
-			for i := byte(0); i < circleArc; i++ {
-				idx := i + circleOrientation + byte(heading+offsetHeading)
-				if idx < 0 {
-					idx += NUMLEDS * 2
-				}
-				idx %= (NUMLEDS * 2)
-				if idx > 0 && idx < NUMLEDS {
-					leds[idx] = c
-					if idx == 13 {
-						success = false
-					}
-				}
-			}
-			//leds[13] = colors[RED]
-
-			circleRadius--
-			if circleRadius < 56 {
-				if !success {
-					game = GAMEOVER
-				}
-				circleArc++
-				circleRadius = 300
-				circleOrientation = byte(randomInt(0, NUMLEDS*2))
-			}
-
-			data = []byte(strconv.Itoa(int(circleArc)))
-			//println("DATA", data, circleArc, int(circleArc), strconv.Itoa(int(circleArc)))
-			publishData(circlesArcTopic, &data)
-			data = []byte(strconv.Itoa(int(circleOrientation)))
-			publishData(circlesOrientationTopic, &data)
-			data = []byte{
-				byte(circleRadius >> 24),
-				byte(circleRadius >> 16),
-				byte(circleRadius >> 8),
-				byte(circleRadius),
-			}
-			publishData(circlesRadiusTopic, &data)
-
-			break
-		case GAMEOVER:
-			for i := 0; i < 5; i++ {
-				for i := range leds {
-					leds[i] = colors[RED]
-				}
-				ws.WriteColors(leds[:])
-				time.Sleep(600 * time.Millisecond)
-				for i := range leds {
-					leds[i] = colors[BLACK]
-				}
-				ws.WriteColors(leds[:])
-				time.Sleep(600 * time.Millisecond)
-			}
-			game = CIRCLE
-			break
-		case MAZE:
-			mapx = px
-			mapy = py
-			if jy.Get() < 1000 {
-				px += int(SPEED * math.Sin(offsetHeadingRads-headingRads))
-				py -= int(SPEED * math.Cos(offsetHeadingRads-headingRads))
-			} else if jy.Get() > 64000 {
-				px -= int(SPEED * math.Sin(offsetHeadingRads-headingRads))
-				py += int(SPEED * math.Cos(offsetHeadingRads-headingRads))
-			}
-			if jx.Get() < 1000 {
-				py -= int(SPEED * math.Sin(offsetHeadingRads-headingRads))
-				px += int(SPEED * math.Cos(offsetHeadingRads-headingRads))
-			} else if jx.Get() > 64000 {
-				py += int(SPEED * math.Sin(offsetHeadingRads-headingRads))
-				px -= int(SPEED * math.Cos(offsetHeadingRads-headingRads))
-			}
-
-			if maze[py/TILESIZE][px/TILESIZE] {
-				px = mapx
-				py = mapy
-			}
-
-			if px < 0 {
-				px = 0
-			} else if px > 9600 {
-				px = 9600
-			}
-			if py < 0 {
-				py = 0
-			} else if py > 9600 {
-				py = 9600
-			}
-
-			for i := float64(0); i < NUMLEDS; i++ {
-				brightness := 300 - castRay(offsetHeadingRads-headingRads-i*(math.Pi/float64(NUMLEDS)))
-				// gamma correction
-				brightness = int(math.Pow(float64(brightness)/255, 2.6) * 255)
-				c := color.RGBA{0, 0, byte(brightness), 255}
-				leds[int(i)] = c
-			}
-			println(int((headingRads*180)/math.Pi), int((offsetHeadingRads*180)/math.Pi), int(((offsetHeadingRads-headingRads)*180)/math.Pi), heading, offsetHeading, ledIndex)
-			//printTile(px, py)
-
-			data = []byte{
-				byte(px >> 24),
-				byte(px >> 16),
-				byte(px >> 8),
-				byte(px),
-				byte(py >> 24),
-				byte(py >> 16),
-				byte(py >> 8),
-				byte(py),
-			}
-			publishData(mazeTopic, &data)
-
-			break
+		if hasEvent(controls.Release, DOWN) {
+			saveChangedSettings()
 		}
-
-		ws.WriteColors(leds[:])
-
-		// PUBLISH TO MQTT
-		data = []byte(strconv.Itoa(ledIndex))
-		publishData(orientationTopic, &data)
-		publishData(ledsTopic, &ledBytes)
-
-		switch mode {
-		case IDLE:
-			pixel := display.GetPixel(x, y)
-			c := colors[WHITE]
-			if pixel {
-				c = colors[BLACK]
-			}
-			display.SetPixel(x, y, c)
-			display.Display()
-
-			x += deltaX
-			y += deltaY
-
-			if x == 0 || x == 127 {
-				deltaX = -deltaX
-			}
-
-			if y == 0 || y == 63 {
-				deltaY = -deltaY
-			}
-			if pressedBtn[UP] {
-				mode = CENTERING
-			}
-			if pressedBtn[DOWN] {
-				offsetHeadingRads++
-			}
-			break
-		case CENTERING:
-			display.ClearDisplay()
-			_, w := tinyfont.LineWidth(&tinyfont.Org01, "CENTERING")
-			tinyfont.WriteLine(display, &tinyfont.Org01, int16(128-w)/2, 40, "CENTERING", colors[WHITE])
-			display.Display()
-			if pressedBtn[UP] {
-				display.ClearDisplay()
-				display.Display()
-				offsetHeading = (NUMLEDS / 2) - heading
-				offsetHeadingRads = headingRads
-				mode = IDLE
-			}
-			break
+		if pressedBtn[HAND] {
+			openMenu()
+		}
+		if calibrationCombo() {
+			startCalibration()
 		}
+		break
+	case STATS:
+		updateStats()
+		break
+	case MENU:
+		updateMenu()
+		break
+	case CENTERING:
+		showMessage("CENTERING")
+		if pressedBtn[UP] {
+			centerView()
+			mode = IDLE
+		}
+		break
+	}
+
+	return nil
+}
+
+// centerView takes where the player is looking now as straight ahead.
+func centerView() {
+	display.ClearDisplay()
+	display.Display()
+	offsetHeading = (NUMLEDS / 2) - heading
+	offsetHeadingRads = headingRads
+	saveSettings()
+}
 
-		time.Sleep(50 * time.Millisecond)
+// hasEvent reports whether button got an event of the given kind this
+// frame.
+func hasEvent(kind controls.Kind, button int) bool {
+	for _, e := range events {
+		if e.Kind == kind && e.Button == button {
+			return true
+		}
 	}
+	return false
 }
 
-// Wait for user to open serial console
-func waitSerial() {
-	for !machine.Serial.DTR() {
-		time.Sleep(100 * time.Millisecond)
+// hasCombo reports whether the buttons in mask were just pressed together.
+func hasCombo(mask uint32) bool {
+	for _, e := range events {
+		if e.Kind == controls.Combo && e.Mask == mask {
+			return true
+		}
 	}
+	return false
+}
+
+func showMessage(msg string) {
+	display.ClearDisplay()
+	_, w := tinyfont.LineWidth(&tinyfont.Org01, msg)
+	tinyfont.WriteLine(display, &tinyfont.Org01, int16(128-w)/2, 40, msg, colors[WHITE])
+	display.Display()
 }
@@ -1,6 +1,17 @@
 package main
 
-import "math"
+import (
+	"image/color"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/conejoninja/vision/compass"
+	"github.com/conejoninja/vision/grid"
+	"github.com/conejoninja/vision/logging"
+	"github.com/conejoninja/vision/race"
+	"github.com/conejoninja/vision/telemetry"
+)
 
 const (
 	MAZESIZE = 32
@@ -9,14 +20,24 @@ const (
 )
 
 var (
-	maze       [32][32]bool
-	px, py     int
-	mapx, mapy int
+	// maze is the classic hand-made level, played when mazeSeed is 0
+	maze     [32][32]bool
+	mazeGrid *grid.Grid
+	level    *grid.Level
+	px, py   int
+
+	// playerRadius is how close to a wall the player can get, in world
+	// units.
+	playerRadius = 60
 
-	dx, dy float64
+	// mazeSeed picks the generated level MAZE plays, share it to play the
+	// same maze somewhere else.
+	mazeSeed              uint32
+	mazeWidth, mazeHeight = 31, 31
 )
 
 func init() {
+	registerGame(MAZE, &mazeGame{})
 
 	maze = [32][32]bool{
 		[32]bool{true, true, true, true, true, true, true, true, true, true, false, false, false, true, false, true, false, false, false, true, true, true, true, true, true, true, true, true, true, true, true, true},
@@ -53,63 +74,317 @@ func init() {
 		[32]bool{true, true, true, true, true, true, true, true, true, true, false, false, false, true, false, true, false, false, false, true, true, true, true, true, true, true, true, true, true, true, true, true},
 	}
 
+	mazeGrid = grid.New(MAZESIZE, MAZESIZE, TILESIZE)
+	for y := range maze {
+		for x := range maze[y] {
+			mazeGrid.Set(x, y, maze[y][x])
+		}
+	}
+}
+
+// mazeLevels is the sequence MAZE goes through: the classic hand-made maze
+// and then generated ones, bigger every time. After the last one every level
+// is a new random maze of the same size.
+var mazeLevels = []struct {
+	seed uint32
+	size int
+}{
+	{0, MAZESIZE},
+	{1, 11},
+	{2, 15},
+	{3, 21},
+	{4, 31},
+}
+
+// mazeCelebration is how long the LEDs party after reaching the exit.
+const mazeCelebration = 3 * time.Second
+
+// mazeGame walks the player around the maze, the LEDs show how far the walls
+// are in every direction in front of them. Reaching the exit tile clears the
+// level and moves on to the next one.
+type mazeGame struct {
+	view      float64
+	levelNum  int
+	elapsed   time.Duration
+	won       bool
+	celebrate time.Duration
+	seedSent  bool
+	timeSent  bool
+}
+
+func (g *mazeGame) Name() string { return "MAZE" }
+
+func currentMaze() *mazeGame {
+	return games[MAZE].(*mazeGame)
+}
+
+func (g *mazeGame) Init() {
+	g.startLevel(0)
+}
+
+// startLevel picks the seed and size of level n of the sequence and starts
+// it.
+func (g *mazeGame) startLevel(n int) {
+	g.levelNum = n
+	if n < len(mazeLevels) {
+		mazeSeed = mazeLevels[n].seed
+		mazeWidth, mazeHeight = mazeLevels[n].size, mazeLevels[n].size
+	} else {
+		mazeSeed = uint32(randomInt(1, math.MaxInt32))
+	}
+	g.loadLevel()
+}
+
+// loadLevel builds the maze for mazeSeed and puts the player at its start.
+func (g *mazeGame) loadLevel() {
+	if mazeSeed == 0 {
+		level = grid.NewLevel(mazeGrid, 2, 1)
+	} else {
+		level = grid.Generate(mazeSeed, mazeWidth, mazeHeight, TILESIZE)
+	}
+	px = level.StartX*TILESIZE + TILESIZE/2
+	py = level.StartY*TILESIZE + TILESIZE/2
+	g.elapsed = 0
+	g.won = false
+	g.seedSent = false
 }
 
-func castRay(rayAngle float64) int {
-	dx = math.Cos(rayAngle)
-	dy = math.Sin(rayAngle)
+// Restart plays the current maze again from its start.
+func (g *mazeGame) Restart() {
+	g.loadLevel()
+}
 
-	for i := float64(1); i < MAXDIST; i += 2 {
-		mapx = (px + int(dx*i)) / TILESIZE
-		mapy = (py + int(dy*i)) / TILESIZE
+// newLevel swaps the current level for a freshly generated maze.
+func (g *mazeGame) newLevel() {
+	mazeSeed = uint32(randomInt(1, math.MaxInt32))
+	g.loadLevel()
+	showLines("NEW MAZE", "SEED "+strconv.FormatUint(uint64(mazeSeed), 10))
+}
 
-		if mapx < 0 || mapy < 0 || mapx >= MAZESIZE || mapy >= MAZESIZE {
-			return int(i)
+func (g *mazeGame) Update(in *Input) {
+	if in.Pressed[MID] && racing {
+		startRace(newRaceSeed(), mazeWidth)
+		return
+	}
+	if g.won {
+		g.celebrate += in.DT
+		// racers stay at the exit until the next race
+		if g.celebrate >= mazeCelebration && !racing {
+			g.startLevel(g.levelNum + 1)
 		}
+		return
+	}
+
+	if in.Pressed[MID] {
+		g.newLevel()
+	}
+	g.view = in.OffsetHeadingRads - in.HeadingRads
+	g.elapsed += in.DT
 
-		if maze[mapy][mapx] {
-			return int(i)
+	// push the stick forward to walk where you look, sideways to strafe
+	step := SPEED * in.DT.Seconds()
+	sin, cos := math.Sincos(g.view)
+	dx := step * (in.StickY*sin + in.StickX*cos)
+	dy := step * (in.StickX*sin - in.StickY*cos)
+	px, py = level.Grid.Move(px, py, int(dx), int(dy), playerRadius)
+	if mazeLog.Enabled(logging.Debug) {
+		mazeLog.Debug(strconv.Itoa(int((in.HeadingRads*180)/math.Pi)), strconv.Itoa(int((in.OffsetHeadingRads*180)/math.Pi)),
+			strconv.Itoa(int((g.view*180)/math.Pi)), strconv.Itoa(in.Heading), strconv.Itoa(in.OffsetHeading), strconv.Itoa(in.LEDIndex))
+	}
+	//printTile(px, py)
+
+	if tx, ty := level.Grid.Tile(px, py); tx == level.ExitX && ty == level.ExitY {
+		g.win()
+	}
+}
+
+// win ends the level, the time it took goes to the OLED and MQTT.
+func (g *mazeGame) win() {
+	g.won = true
+	g.celebrate = 0
+	g.timeSent = false
+	if racing {
+		// who won the race comes back from the broker
+		sendRace(race.Finish)
+		showLines("FINISHED", "TIME "+seconds(g.elapsed))
+		return
+	}
+	showLines("LEVEL "+strconv.Itoa(g.levelNum+1)+" CLEARED",
+		"TIME "+seconds(g.elapsed),
+		"NEXT: LEVEL "+strconv.Itoa(g.levelNum+2))
+}
+
+// seconds formats d as seconds with one decimal.
+func seconds(d time.Duration) string {
+	tenths := int(d / (100 * time.Millisecond))
+	return strconv.Itoa(tenths/10) + "." + strconv.Itoa(tenths%10) + "S"
+}
+
+func (g *mazeGame) Render(leds []color.RGBA) {
+	if g.won && g.celebrate < mazeCelebration {
+		// rainbow running around the strip
+		shift := int(g.celebrate / (20 * time.Millisecond))
+		for i := range leds {
+			leds[i] = wheel(byte(i*256/len(leds) + shift))
+		}
+		return
+	}
+
+	for i := range leds {
+		dist, face := castRay(g.rayAngle(i, len(leds)))
+		brightness := 300 - dist
+		if brightness > 255 {
+			brightness = 255
+		}
+		// gamma correction
+		brightness = int(math.Pow(float64(brightness)/255, 2.6) * 255)
+		// walls facing east and west are a bit darker, so corners stand out
+		if face == grid.East || face == grid.West {
+			brightness = brightness * 3 / 4
 		}
+		leds[i] = shade(colors[BLUE], brightness)
+	}
+
+	// the exit glows green when it is in sight
+	g.blip(leds, level.ExitX*TILESIZE+TILESIZE/2, level.ExitY*TILESIZE+TILESIZE/2, colors[GREEN])
+	if racing {
+		renderRacers(g, leds)
 	}
-	return MAXDIST
 }
 
-func printTile(x, y int) {
+// rayAngle is the direction LED i of n looks at, they cover half a turn
+// centred on where the player is facing.
+func (g *mazeGame) rayAngle(i, n int) float64 {
+	return g.view - float64(i)*(math.Pi/float64(n))
+}
 
+// blip lights the LED looking at the world position (x, y) with c, faded
+// with distance, as long as no wall is in the way.
+func (g *mazeGame) blip(leds []color.RGBA, x, y int, c color.RGBA) {
+	dx, dy := float64(x-px), float64(y-py)
+	dist := math.Sqrt(dx*dx + dy*dy)
+	if dist >= MAXDIST {
+		return
+	}
+	step := math.Pi / float64(len(leds))
+	i := int(math.Floor(compass.Wrap(g.view-math.Atan2(dy, dx))/step + 0.5))
+	if i < 0 || i >= len(leds) {
+		return
+	}
+	if wall, _ := castRay(g.rayAngle(i, len(leds))); float64(wall) < dist {
+		return
+	}
+	leds[i] = shade(c, int(255*(1-dist/MAXDIST)))
+}
+
+// shade dims c to level, from 0 to 255.
+func shade(c color.RGBA, level int) color.RGBA {
+	return color.RGBA{byte(int(c.R) * level / 255), byte(int(c.G) * level / 255), byte(int(c.B) * level / 255), 255}
+}
+
+// wheel goes around the colour wheel, red to green to blue and back, as pos
+// goes from 0 to 255.
+func wheel(pos byte) color.RGBA {
+	switch {
+	case pos < 85:
+		return color.RGBA{255 - pos*3, pos * 3, 0, 255}
+	case pos < 170:
+		pos -= 85
+		return color.RGBA{0, 255 - pos*3, pos * 3, 255}
+	default:
+		pos -= 170
+		return color.RGBA{pos * 3, 0, 255 - pos*3, 255}
+	}
+}
+
+func (g *mazeGame) Telemetry(section []byte) []byte {
+	return telemetry.Maze{
+		X:       int32(px),
+		Y:       int32(py),
+		Seed:    level.Seed,
+		Level:   uint8(g.levelNum + 1),
+		Cleared: g.won,
+		Elapsed: uint32(g.elapsed / time.Millisecond),
+	}.Append(section)
+}
+
+func (g *mazeGame) Publish() {
+	data = []byte{
+		byte(px >> 24),
+		byte(px >> 16),
+		byte(px >> 8),
+		byte(px),
+		byte(py >> 24),
+		byte(py >> 16),
+		byte(py >> 8),
+		byte(py),
+	}
+	publishData(mazeTopic, &data)
+
+	if !g.seedSent {
+		data = []byte(strconv.FormatUint(uint64(level.Seed), 10))
+		publishData(mazeSeedTopic, &data)
+		g.seedSent = true
+	}
+
+	if g.won && !g.timeSent {
+		// level number and completion time in milliseconds, big endian
+		n := g.levelNum + 1
+		ms := int(g.elapsed / time.Millisecond)
+		data = []byte{
+			byte(n >> 24),
+			byte(n >> 16),
+			byte(n >> 8),
+			byte(n),
+			byte(ms >> 24),
+			byte(ms >> 16),
+			byte(ms >> 8),
+			byte(ms),
+		}
+		publishData(mazeTimeTopic, &data)
+		g.timeSent = true
+	}
+}
+
+// castRay returns how far the nearest wall is from the player along
+// rayAngle, and which side of it is facing the player.
+func castRay(rayAngle float64) (int, grid.Face) {
+	dx, dy := grid.Direction(rayAngle)
+	hit := level.Grid.Cast(px, py, dx, dy, MAXDIST)
+	return hit.Dist, hit.Face
+}
+
+func printTile(x, y int) {
+	if !mazeLog.Enabled(logging.Debug) {
+		return
+	}
 	tx := x / 300
 	ty := y / 300
-	println(x, y, tx, ty)
-
 	x = (x % 300) / 30
 	y = (y % 300) / 30
+	mazeLog.Debug("tile", strconv.Itoa(tx), strconv.Itoa(ty), "at", strconv.Itoa(x), strconv.Itoa(y))
 
-	println(x, y, tx, ty)
+	var row [12]byte
 	for j := -1; j <= 1; j++ {
 		for i := -1; i <= 1; i++ {
-			if ty+j < 0 || ty+j > 31 ||
-				tx+i < 0 || tx+i > 31 {
-				print(" ")
-			} else if maze[ty+j][tx+i] {
-				print("#")
-			} else {
-				print(" ")
+			row[i+1] = ' '
+			if level.Grid.Inside(tx+i, ty+j) && level.Grid.Wall(tx+i, ty+j) {
+				row[i+1] = '#'
 			}
 		}
-		println("")
+		mazeLog.Debug(string(row[:3]))
 	}
 
-	println("+----------+")
+	mazeLog.Debug("+----------+")
 	for j := 0; j < 10; j++ {
-		print("|")
+		row[0], row[11] = '|', '|'
 		for i := 0; i < 10; i++ {
+			row[1+i] = ' '
 			if x == i && y == j {
-				print("X")
-			} else {
-				print(" ")
+				row[1+i] = 'X'
 			}
 		}
-		println("|")
+		mazeLog.Debug(string(row[:]))
 	}
-	println("+----------+")
-
+	mazeLog.Debug("+----------+")
 }
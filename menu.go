@@ -0,0 +1,181 @@
+package main
+
+import (
+	"image/color"
+	"strconv"
+
+	"github.com/conejoninja/vision/controls"
+	"github.com/conejoninja/vision/menu"
+)
+
+// brightness of the LEDs in percent, set from the menu.
+var brightness = 100
+
+var (
+	mainMenu = menu.Menu{Title: "GOPHER VISION"}
+	// menuDirty is set when the menu has to be drawn again.
+	menuDirty bool
+)
+
+// buildMenu fills the main menu with every registered game followed by the
+// settings.
+func buildMenu() {
+	mainMenu.Items = mainMenu.Items[:0]
+	for _, id := range playableGames() {
+		id := id
+		mainMenu.Items = append(mainMenu.Items, menu.Item{
+			Label: "PLAY " + games[id].Name(),
+			Value: func() string {
+				if game == id {
+					return "*"
+				}
+				return ""
+			},
+			Select: func() {
+				switchGame(id)
+				closeMenu()
+			},
+		})
+	}
+
+	mainMenu.Items = append(mainMenu.Items,
+		menu.Item{
+			Label: "RACE",
+			Value: func() string {
+				if racing {
+					return strconv.Itoa(len(mazeRace.Peers)+1) + " IN"
+				}
+				return "OFF"
+			},
+			Select: func() {
+				if racing {
+					leaveRace()
+					return
+				}
+				closeMenu()
+				joinRace()
+			},
+		},
+		menu.Item{
+			Label: "CENTER",
+			Select: func() {
+				closeMenu()
+				mode = CENTERING
+			},
+		},
+		menu.Item{
+			Label: "CALIBRATE",
+			Select: func() {
+				closeMenu()
+				startCalibration()
+			},
+		},
+		menu.Item{
+			Label: "BRIGHTNESS",
+			Value: func() string { return strconv.Itoa(brightness) + "%" },
+			Adjust: func(delta int) {
+				brightness += 10 * delta
+				if brightness < 10 {
+					brightness = 10
+				} else if brightness > 100 {
+					brightness = 100
+				}
+			},
+		},
+		menu.Item{
+			Label:  "WIFI",
+			Value:  func() string { return onOff(useWifi) },
+			Select: func() { setWifi(!useWifi) },
+			Adjust: func(int) { setWifi(!useWifi) },
+		},
+		menu.Item{
+			Label: "MQTT",
+			Value: func() string {
+				if useMQTT && useWifi {
+					return network.State().String()
+				}
+				return onOff(useMQTT)
+			},
+			Select: func() { setMQTT(!useMQTT) },
+			Adjust: func(int) { setMQTT(!useMQTT) },
+		},
+		menu.Item{
+			Label: "FRAME STATS",
+			Select: func() {
+				closeMenu()
+				mode = STATS
+			},
+		},
+		menu.Item{
+			Label:  "BACK",
+			Select: closeMenu,
+		},
+	)
+}
+
+func onOff(b bool) string {
+	if b {
+		return "ON"
+	}
+	return "OFF"
+}
+
+func openMenu() {
+	buildMenu()
+	mainMenu.Reset()
+	menuDirty = true
+	mode = MENU
+}
+
+// closeMenu goes back to IDLE, keeping whatever was changed.
+func closeMenu() {
+	saveChangedSettings()
+	display.ClearDisplay()
+	display.Display()
+	mode = IDLE
+}
+
+// updateMenu runs one frame of MENU mode: UP and DOWN move, MID picks, LEFT
+// and RIGHT change settings and HAND leaves.
+func updateMenu() {
+	for _, e := range events {
+		if e.Kind != controls.Press && e.Kind != controls.Repeat {
+			continue
+		}
+		menuDirty = true
+		switch e.Button {
+		case UP:
+			mainMenu.Move(-1)
+		case DOWN:
+			mainMenu.Move(1)
+		case LEFT:
+			mainMenu.Adjust(-1)
+		case RIGHT:
+			mainMenu.Adjust(1)
+		case MID:
+			if e.Kind == controls.Press {
+				mainMenu.Select()
+			}
+		case HAND:
+			if e.Kind == controls.Press {
+				closeMenu()
+			}
+		}
+	}
+	if mode == MENU && menuDirty {
+		mainMenu.Draw(display)
+		menuDirty = false
+	}
+}
+
+// applyBrightness dims the frame about to be sent to the strip.
+func applyBrightness(frame []color.RGBA) {
+	if brightness >= 100 {
+		return
+	}
+	for i := range frame {
+		frame[i].R = byte(int(frame[i].R) * brightness / 100)
+		frame[i].G = byte(int(frame[i].G) * brightness / 100)
+		frame[i].B = byte(int(frame[i].B) * brightness / 100)
+	}
+}
@@ -0,0 +1,140 @@
+// Package menu draws a scrollable list of items on the OLED and lets the
+// buttons pick them.
+package menu
+
+import (
+	"image/color"
+
+	"github.com/conejoninja/vision/hal"
+	"tinygo.org/x/tinyfont"
+)
+
+const (
+	// RowHeight is the height of a line of the menu in pixels.
+	RowHeight = 9
+	// baseline of the Org01 font within a row
+	baseline = 7
+)
+
+var (
+	white = color.RGBA{255, 255, 255, 255}
+	black = color.RGBA{0, 0, 0, 255}
+)
+
+// Item is a line of the menu.
+type Item struct {
+	Label string
+	// Value, when set, is shown at the right of the line, for settings.
+	Value func() string
+	// Select runs when the item is picked.
+	Select func()
+	// Adjust runs when the item is nudged left (-1) or right (+1).
+	Adjust func(delta int)
+}
+
+// Menu is a title and a list of items, of which one is selected. Only the
+// items that fit below the title are drawn, the list scrolls to keep the
+// selected one in sight.
+type Menu struct {
+	Title string
+	Items []Item
+
+	selected, top int
+}
+
+// Selected returns the index of the selected item.
+func (m *Menu) Selected() int {
+	return m.selected
+}
+
+// Reset selects the first item and scrolls back to the top.
+func (m *Menu) Reset() {
+	m.selected, m.top = 0, 0
+}
+
+// Move selects the item delta lines below the current one, wrapping around
+// the ends of the list.
+func (m *Menu) Move(delta int) {
+	if len(m.Items) == 0 {
+		return
+	}
+	m.selected = (m.selected + delta) % len(m.Items)
+	if m.selected < 0 {
+		m.selected += len(m.Items)
+	}
+}
+
+// Select runs the selected item.
+func (m *Menu) Select() {
+	if m.selected < len(m.Items) && m.Items[m.selected].Select != nil {
+		m.Items[m.selected].Select()
+	}
+}
+
+// Adjust nudges the selected item.
+func (m *Menu) Adjust(delta int) {
+	if m.selected < len(m.Items) && m.Items[m.selected].Adjust != nil {
+		m.Items[m.selected].Adjust(delta)
+	}
+}
+
+// rows returns how many items fit on d below the title.
+func (m *Menu) rows(d hal.Display) int {
+	_, h := d.Size()
+	return int(h)/RowHeight - 1
+}
+
+// Draw renders the menu on d and sends it to the screen.
+func (m *Menu) Draw(d hal.Display) {
+	rows := m.rows(d)
+	if m.selected < m.top {
+		m.top = m.selected
+	} else if m.selected >= m.top+rows {
+		m.top = m.selected - rows + 1
+	}
+
+	w, _ := d.Size()
+	d.ClearDisplay()
+	_, tw := tinyfont.LineWidth(&tinyfont.Org01, m.Title)
+	tinyfont.WriteLine(d, &tinyfont.Org01, (w-int16(tw))/2, baseline, m.Title, white)
+	for x := int16(0); x < w; x++ {
+		d.SetPixel(x, RowHeight-1, white)
+	}
+
+	for row := 0; row < rows && m.top+row < len(m.Items); row++ {
+		i := m.top + row
+		item := &m.Items[i]
+		y := int16((row + 1) * RowHeight)
+		fg := white
+		if i == m.selected {
+			// the selected line is drawn inverted
+			for yy := y; yy < y+RowHeight; yy++ {
+				for x := int16(0); x < w; x++ {
+					d.SetPixel(x, yy, white)
+				}
+			}
+			fg = black
+		}
+		tinyfont.WriteLine(d, &tinyfont.Org01, 2, y+baseline, item.Label, fg)
+		if item.Value != nil {
+			v := item.Value()
+			_, vw := tinyfont.LineWidth(&tinyfont.Org01, v)
+			tinyfont.WriteLine(d, &tinyfont.Org01, w-2-int16(vw), y+baseline, v, fg)
+		}
+	}
+
+	// little arrows in the title bar when there is more above or below
+	if m.top > 0 {
+		d.SetPixel(3, 1, white)
+		d.SetPixel(2, 2, white)
+		d.SetPixel(3, 2, white)
+		d.SetPixel(4, 2, white)
+	}
+	if m.top+rows < len(m.Items) {
+		d.SetPixel(w-5, 5, white)
+		d.SetPixel(w-4, 5, white)
+		d.SetPixel(w-3, 5, white)
+		d.SetPixel(w-4, 6, white)
+	}
+	d.Display()
+}
@@ -0,0 +1,117 @@
+package menu
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/conejoninja/vision/hal"
+)
+
+// items returns n items that record which one was last picked or nudged.
+func items(n int, picked, nudged *int) []Item {
+	list := make([]Item, n)
+	for i := range list {
+		i := i
+		list[i] = Item{
+			Label:  "ITEM " + strconv.Itoa(i),
+			Select: func() { *picked = i },
+			Adjust: func(delta int) { *nudged = i * delta },
+		}
+	}
+	return list
+}
+
+func TestMove(t *testing.T) {
+	var picked, nudged int
+	m := Menu{Items: items(4, &picked, &nudged)}
+	for _, tt := range []struct{ delta, want int }{
+		{1, 1},
+		{1, 2},
+		{-1, 1},
+		// round both ends
+		{-2, 3},
+		{1, 0},
+		{9, 1},
+		{-6, 3},
+	} {
+		m.Move(tt.delta)
+		if got := m.Selected(); got != tt.want {
+			t.Errorf("Move(%d) selected %d, want %d", tt.delta, got, tt.want)
+		}
+	}
+
+	m.Reset()
+	if m.Selected() != 0 {
+		t.Errorf("Reset selected %d", m.Selected())
+	}
+}
+
+func TestSelectAndAdjust(t *testing.T) {
+	picked, nudged := -1, 0
+	m := Menu{Items: items(4, &picked, &nudged)}
+	m.Move(2)
+	m.Select()
+	if picked != 2 {
+		t.Errorf("picked %d, want 2", picked)
+	}
+	m.Adjust(-1)
+	if nudged != -2 {
+		t.Errorf("nudged %d, want -2", nudged)
+	}
+
+	// items without actions, and no items at all, do nothing
+	m.Items[2] = Item{Label: "TITLE"}
+	m.Select()
+	m.Adjust(1)
+	empty := Menu{}
+	empty.Move(1)
+	empty.Select()
+	empty.Adjust(1)
+	if empty.Selected() != 0 {
+		t.Errorf("empty menu selected %d", empty.Selected())
+	}
+}
+
+func TestDrawScrolls(t *testing.T) {
+	var picked, nudged int
+	m := Menu{Title: "MENU", Items: items(10, &picked, &nudged)}
+	fb := hal.NewFramebuffer(128, 64)
+	rows := m.rows(fb)
+	if rows != 6 {
+		t.Fatalf("%d rows fit, want 6", rows)
+	}
+
+	// inverted is whether the line of row is drawn selected
+	inverted := func(row int) bool {
+		return fb.GetPixel(0, int16((row+1)*RowHeight))
+	}
+	up := func() bool { return fb.GetPixel(3, 1) }
+	down := func() bool { return fb.GetPixel(124, 6) }
+
+	for _, tt := range []struct {
+		move, row int
+		up, down  bool
+	}{
+		{0, 0, false, true},
+		{5, 5, false, true},
+		// one more scrolls by one line
+		{1, 5, true, true},
+		{3, 5, true, false},
+		// going up keeps the view until the top line
+		{-5, 0, true, false},
+		{-4, 0, false, true},
+		// wrapping to the end shows the last lines
+		{-1, 5, true, false},
+	} {
+		m.Move(tt.move)
+		m.Draw(fb)
+		for row := 0; row < rows; row++ {
+			if inverted(row) != (row == tt.row) {
+				t.Errorf("item %d: row %d inverted %v", m.Selected(), row, inverted(row))
+			}
+		}
+		if up() != tt.up || down() != tt.down {
+			t.Errorf("item %d: arrows up %v down %v, want %v %v", m.Selected(), up(), down(), tt.up, tt.down)
+		}
+	}
+}
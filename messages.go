@@ -1,3 +1,10 @@
 package main
 
-const DeviceID = "vision3000"
+// DeviceID names this headset on the broker, every topic it uses lives under
+// it. Give each headset its own when building with
+// -ldflags "-X main.DeviceID=vision3001".
+var DeviceID = "vision3000"
+
+// FirmwareVersion is announced on the broker, set it when building with
+// -ldflags "-X main.FirmwareVersion=1.2.3".
+var FirmwareVersion = "dev"
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"image/color"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/conejoninja/vision/logging"
+	"github.com/conejoninja/vision/queue"
+	"github.com/conejoninja/vision/race"
+)
+
+var (
+	// racing is set while this headset races others through the maze.
+	racing    bool
+	mazeRace  = race.Race{Timeout: 3 * time.Second}
+	raceInbox = queue.New[raceMessage](16)
+	// raceEvery is how often the position goes out during a race.
+	raceEvery = 100 * time.Millisecond
+	raceSince time.Duration
+	raceBuf   []byte
+
+	// peerColors tell the other racers apart, by their slot in the race.
+	peerColors = []color.RGBA{
+		{255, 0, 0, 255},
+		{255, 160, 0, 255},
+		{255, 0, 255, 255},
+		{255, 255, 255, 255},
+	}
+)
+
+// raceMessage is a race message together with the headset that sent it.
+type raceMessage struct {
+	device string
+	m      race.Message
+}
+
+// receiveRace is called from the MQTT reader goroutine, it only decodes and
+// queues.
+func receiveRace(device string, payload []byte) {
+	m, err := race.Decode(payload)
+	if err != nil {
+		if raceLog.Enabled(logging.Warn) {
+			raceLog.Warn(device, err.Error())
+		}
+		return
+	}
+	raceInbox.Push(raceMessage{device: device, m: m})
+}
+
+// joinRace starts racing on whatever maze the others are on, or on the
+// current one if nobody else is around.
+func joinRace() {
+	if game != MAZE {
+		switchGame(MAZE)
+	}
+	racing = true
+	// whatever arrived before is about some other race
+	raceInbox.Clear()
+	mazeRace.Self = DeviceID
+	mazeRace.Clear()
+	mazeRace.Reset(mazeSeed, uint8(mazeWidth))
+	mazeRace.Join()
+	sendRace(race.Join)
+	showLines("RACE", "WAITING FOR PLAYERS")
+}
+
+// startRace puts every racer on a new maze, from its start.
+func startRace(seed uint32, size int) {
+	if !racing {
+		joinRace()
+	}
+	if size == 0 {
+		size = mazeWidth
+	}
+	mazeRace.Reset(seed, uint8(size))
+	sendRace(race.Start)
+	loadRace()
+}
+
+// leaveRace goes back to playing alone.
+func leaveRace() {
+	if !racing {
+		return
+	}
+	sendRace(race.Leave)
+	racing = false
+	mazeRace.Clear()
+}
+
+// loadRace builds the maze of the race and goes back to its start.
+func loadRace() {
+	mazeSeed = mazeRace.Seed
+	mazeWidth, mazeHeight = int(mazeRace.Size), int(mazeRace.Size)
+	currentMaze().Restart()
+	showLines("RACE", "SEED "+strconv.FormatUint(uint64(mazeSeed), 10), "GO!")
+}
+
+// updateRace runs one frame of the race: it handles what the others sent,
+// drops the ones that went quiet and sends where this player is.
+func updateRace(dt time.Duration) {
+	if !racing {
+		// nobody is listening, do not let old news pile up
+		raceInbox.Clear()
+		return
+	}
+	for {
+		e, ok := raceInbox.Pop()
+		if !ok {
+			break
+		}
+		raceEvent(mazeRace.Receive(e.device, e.m))
+	}
+	for _, device := range mazeRace.Update(dt) {
+		raceLog.Info(device, "timed out")
+	}
+
+	raceSince += dt
+	if raceSince >= raceEvery {
+		raceSince = 0
+		sendRace(race.State)
+	}
+}
+
+func raceEvent(e race.Event) {
+	switch e.Kind {
+	case race.Started:
+		raceLog.Info("started by", e.Device)
+		loadRace()
+	case race.Joined:
+		raceLog.Info(e.Device, "joined")
+	case race.Asked:
+		raceLog.Info(e.Device, "wants to race")
+		sendRace(race.State)
+	case race.Left:
+		raceLog.Info(e.Device, "left")
+	case race.Won:
+		raceLog.Info("won by", e.Device)
+		if e.Device == DeviceID {
+			showLines("RACE WON", "TIME "+seconds(currentMaze().elapsed), "MID: RACE AGAIN")
+		} else {
+			showLines("RACE LOST", e.Device+" WON")
+		}
+	}
+}
+
+// sendRace tells the others about this player.
+func sendRace(kind race.Kind) {
+	g := currentMaze()
+	m := race.Message{
+		Kind:    kind,
+		Seed:    mazeRace.Seed,
+		Size:    mazeRace.Size,
+		X:       int32(px),
+		Y:       int32(py),
+		Elapsed: uint32(g.elapsed / time.Millisecond),
+	}
+	raceBuf = m.Append(raceBuf[:0])
+	// a Finish must not be merged into the State after it
+	publishNow(raceTopic, &raceBuf)
+}
+
+// renderRacers draws the other players on this maze as blips.
+func renderRacers(g *mazeGame, leds []color.RGBA) {
+	for i := range mazeRace.Peers {
+		p := &mazeRace.Peers[i]
+		if p.Seed != mazeRace.Seed {
+			continue
+		}
+		g.blip(leds, int(p.X), int(p.Y), peerColors[p.Slot%len(peerColors)])
+	}
+}
+
+// newRaceSeed picks the maze of the next race.
+func newRaceSeed() uint32 {
+	return uint32(randomInt(1, math.MaxInt32))
+}
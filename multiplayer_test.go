@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/conejoninja/vision/race"
+)
+
+func TestJoinRaceIgnoresOldMessages(t *testing.T) {
+	b := newTestBoard(t, MAZE)
+	leaveRace()
+	b.run(t, 2)
+	seed := mazeSeed
+
+	// a race somebody started while this headset was not racing
+	old := race.Message{Kind: race.Start, Seed: seed + 1, Size: uint8(mazeWidth)}
+	receiveRace("vision9", old.Append(nil))
+	b.run(t, 1)
+	receiveRace("vision9", old.Append(nil))
+
+	joinRace()
+	defer leaveRace()
+	b.run(t, 2)
+	if mazeSeed != seed || mazeRace.Seed != seed {
+		t.Errorf("joined on seed %d from an old Start, want %d", mazeRace.Seed, seed)
+	}
+
+	// while a race started now is followed
+	now := race.Message{Kind: race.Start, Seed: seed + 2, Size: uint8(mazeWidth)}
+	receiveRace("vision9", now.Append(nil))
+	b.run(t, 1)
+	if mazeSeed != seed+2 {
+		t.Errorf("on seed %d, want the new race's %d", mazeSeed, seed+2)
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"image/color"
+)
+
+func init() {
+	registerGame(NORTH, &northGame{})
+}
+
+// northGame lights the LED that points north.
+type northGame struct {
+	ledIndex int
+}
+
+func (g *northGame) Name() string { return "NORTH" }
+
+func (g *northGame) Init() {}
+
+func (g *northGame) Update(in *Input) {
+	g.ledIndex = in.LEDIndex
+}
+
+func (g *northGame) Render(leds []color.RGBA) {
+	if g.ledIndex >= 0 && g.ledIndex < len(leds) {
+		leds[g.ledIndex] = colors[RED]
+	}
+}
+
+func (g *northGame) Publish() {}
@@ -0,0 +1,215 @@
+// Package outbox sits between the game loop and the broker. It limits how
+// often every topic goes out, skips payloads that did not change enough and
+// keeps a bounded queue, so a slow link loses old frames instead of slowing
+// the game down.
+package outbox
+
+import (
+	"bytes"
+	"time"
+)
+
+// Rule says how a topic is published.
+type Rule struct {
+	// Every is the shortest time between two messages on the topic, zero
+	// has no limit. A payload that comes too soon waits for its turn and is
+	// replaced by newer ones in the meantime.
+	Every time.Duration
+	// Same reports whether next is close enough to last, the last payload
+	// sent, to be skipped. Nil sends everything.
+	Same func(last, next []byte) bool
+	// Keep resends an unchanged payload once it is this old, so whoever
+	// subscribes later gets it. Zero never resends.
+	Keep time.Duration
+}
+
+// Stats counts what happened to the payloads handed to the outbox.
+type Stats struct {
+	// Sent went out, Failed were handed to Send which returned an error.
+	Sent   uint32
+	Failed uint32
+	// Skipped were the Same as the last one sent.
+	Skipped uint32
+	// Dropped were replaced by a newer payload or pushed out of a full
+	// queue before they went out.
+	Dropped uint32
+}
+
+// Outbox queues payloads and sends them when their topic's Rule allows.
+// It is not safe for concurrent use, it is meant to be driven by the game
+// loop.
+type Outbox struct {
+	// Send publishes a payload, it is only called from Flush.
+	Send func(topic string, payload []byte) error
+	// Rules per topic, topics without one use Default.
+	Rules   map[string]Rule
+	Default Rule
+	// Size is how many topics can wait in the queue, zero is unbounded.
+	Size int
+	// Budget is how many messages a Flush sends at most, zero is
+	// unbounded.
+	Budget int
+
+	Stats Stats
+
+	topics map[string]*entry
+	queue  []*entry
+}
+
+type entry struct {
+	topic   string
+	rule    Rule
+	last    []byte
+	pending []byte
+	queued  bool
+	sent    bool
+	// age is the time since the last message went out
+	age time.Duration
+}
+
+// Publish hands payload over for topic. It never blocks and the payload is
+// copied, so the caller can reuse it.
+func (o *Outbox) Publish(topic string, payload []byte) {
+	e := o.entry(topic)
+	if e.sent && e.rule.Same != nil && e.rule.Same(e.last, payload) &&
+		(e.rule.Keep == 0 || e.age < e.rule.Keep) {
+		o.Stats.Skipped++
+		if e.queued {
+			// what was waiting is stale now, the last one sent is current
+			o.unqueue(e)
+			o.Stats.Dropped++
+		}
+		return
+	}
+	if e.queued {
+		o.Stats.Dropped++
+	} else {
+		if o.Size > 0 && len(o.queue) >= o.Size {
+			o.unqueue(o.queue[0])
+			o.Stats.Dropped++
+		}
+		e.queued = true
+		o.queue = append(o.queue, e)
+	}
+	e.pending = append(e.pending[:0], payload...)
+}
+
+// Update advances the rate limits by dt.
+func (o *Outbox) Update(dt time.Duration) {
+	for _, e := range o.topics {
+		e.age += dt
+	}
+}
+
+// Flush sends what is due, oldest first, up to Budget messages.
+func (o *Outbox) Flush() {
+	sent := 0
+	for i := 0; i < len(o.queue); {
+		if o.Budget > 0 && sent >= o.Budget {
+			return
+		}
+		e := o.queue[i]
+		if e.sent && e.age < e.rule.Every {
+			i++
+			continue
+		}
+		o.unqueue(e)
+		sent++
+		if err := o.Send(e.topic, e.pending); err != nil {
+			o.Stats.Failed++
+			continue
+		}
+		o.Stats.Sent++
+		e.last, e.pending = e.pending, e.last
+		e.sent = true
+		e.age = 0
+	}
+}
+
+// Queued is how many topics are waiting to go out.
+func (o *Outbox) Queued() int {
+	return len(o.queue)
+}
+
+// Reset forgets everything sent and queued, after a new session starts
+// nothing on the broker can be assumed.
+func (o *Outbox) Reset() {
+	for _, e := range o.topics {
+		e.sent = false
+		e.queued = false
+	}
+	o.queue = o.queue[:0]
+}
+
+func (o *Outbox) entry(topic string) *entry {
+	e, ok := o.topics[topic]
+	if ok {
+		return e
+	}
+	if o.topics == nil {
+		o.topics = make(map[string]*entry)
+	}
+	rule, ok := o.Rules[topic]
+	if !ok {
+		rule = o.Default
+	}
+	e = &entry{topic: topic, rule: rule}
+	o.topics[topic] = e
+	return e
+}
+
+func (o *Outbox) unqueue(e *entry) {
+	for i, q := range o.queue {
+		if q == e {
+			o.queue = append(o.queue[:i], o.queue[i+1:]...)
+			break
+		}
+	}
+	e.queued = false
+}
+
+// Equal skips payloads identical to the last one.
+func Equal(last, next []byte) bool {
+	return bytes.Equal(last, next)
+}
+
+// Deadband skips ASCII integer payloads, like "42", that moved by less than
+// band from the last one. Anything else is compared byte by byte.
+func Deadband(band int) func(last, next []byte) bool {
+	return func(last, next []byte) bool {
+		a, okA := atoi(last)
+		b, okB := atoi(next)
+		if !okA || !okB {
+			return bytes.Equal(last, next)
+		}
+		d := a - b
+		if d < 0 {
+			d = -d
+		}
+		return d < band
+	}
+}
+
+func atoi(b []byte) (int, bool) {
+	if len(b) == 0 {
+		return 0, false
+	}
+	neg := b[0] == '-'
+	if neg {
+		b = b[1:]
+		if len(b) == 0 {
+			return 0, false
+		}
+	}
+	n := 0
+	for _, c := range b {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+		n = n*10 + int(c-'0')
+	}
+	if neg {
+		n = -n
+	}
+	return n, true
+}
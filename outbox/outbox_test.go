@@ -0,0 +1,226 @@
+package outbox
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// wire records what an outbox sends, and fails while err is set.
+type wire struct {
+	sent []string
+	err  error
+}
+
+func (w *wire) send(topic string, payload []byte) error {
+	if w.err != nil {
+		return w.err
+	}
+	w.sent = append(w.sent, topic+"="+string(payload))
+	return nil
+}
+
+// take returns what was sent since the last call.
+func (w *wire) take() []string {
+	s := w.sent
+	w.sent = nil
+	return s
+}
+
+func newOutbox(w *wire) *Outbox {
+	return &Outbox{Send: w.send, Rules: map[string]Rule{}}
+}
+
+// expect fails unless the outbox sent want since the last call.
+func expect(t *testing.T, w *wire, want ...string) {
+	t.Helper()
+	if got := w.take(); !reflect.DeepEqual(got, want) {
+		t.Errorf("sent %q, want %q", got, want)
+	}
+}
+
+func TestEvery(t *testing.T) {
+	w := &wire{}
+	o := newOutbox(w)
+	o.Rules["leds"] = Rule{Every: 100 * time.Millisecond}
+
+	o.Publish("leds", []byte("1"))
+	o.Flush()
+	expect(t, w, "leds=1")
+
+	// too soon, it waits and newer payloads replace it
+	o.Publish("leds", []byte("2"))
+	o.Update(50 * time.Millisecond)
+	o.Flush()
+	expect(t, w)
+	o.Publish("leds", []byte("3"))
+	o.Update(49 * time.Millisecond)
+	o.Flush()
+	expect(t, w)
+	o.Update(time.Millisecond)
+	o.Flush()
+	expect(t, w, "leds=3")
+	if o.Stats.Dropped != 1 || o.Queued() != 0 {
+		t.Errorf("%d dropped, %d queued, want 1 and 0", o.Stats.Dropped, o.Queued())
+	}
+
+	// other topics are not held back
+	o.Publish("leds", []byte("4"))
+	o.Publish("pitch", []byte("5"))
+	o.Flush()
+	expect(t, w, "pitch=5")
+}
+
+func TestSameAndKeep(t *testing.T) {
+	w := &wire{}
+	o := newOutbox(w)
+	o.Rules["status"] = Rule{Same: Equal, Keep: time.Second}
+
+	o.Publish("status", []byte("online"))
+	o.Flush()
+	expect(t, w, "status=online")
+
+	o.Update(500 * time.Millisecond)
+	o.Publish("status", []byte("online"))
+	o.Flush()
+	expect(t, w)
+
+	// once it is Keep old it goes out again
+	o.Update(500 * time.Millisecond)
+	o.Publish("status", []byte("online"))
+	o.Flush()
+	expect(t, w, "status=online")
+
+	o.Publish("status", []byte("offline"))
+	o.Flush()
+	expect(t, w, "status=offline")
+	if o.Stats.Skipped != 1 || o.Stats.Sent != 3 {
+		t.Errorf("stats %+v, want 1 skipped and 3 sent", o.Stats)
+	}
+}
+
+func TestDeadband(t *testing.T) {
+	w := &wire{}
+	o := newOutbox(w)
+	o.Default = Rule{Same: Deadband(5)}
+
+	for _, p := range []string{"100", "104", "96", "95", "99", "-3", "-7", "-8", "off", "off", "on"} {
+		o.Publish("pitch", []byte(p))
+		o.Flush()
+	}
+	expect(t, w, "pitch=100", "pitch=95", "pitch=-3", "pitch=-8", "pitch=off", "pitch=on")
+	if o.Stats.Skipped != 5 {
+		t.Errorf("%d skipped, want 5", o.Stats.Skipped)
+	}
+}
+
+func TestSkipDropsStalePending(t *testing.T) {
+	w := &wire{}
+	o := newOutbox(w)
+	o.Rules["leds"] = Rule{Every: time.Second, Same: Equal}
+
+	o.Publish("leds", []byte("a"))
+	o.Flush()
+	expect(t, w, "leds=a")
+	// b waits its turn, but then it is back to what was sent
+	o.Publish("leds", []byte("b"))
+	o.Publish("leds", []byte("a"))
+	o.Update(time.Second)
+	o.Flush()
+	expect(t, w)
+	if o.Stats.Skipped != 1 || o.Stats.Dropped != 1 {
+		t.Errorf("stats %+v, want 1 skipped and 1 dropped", o.Stats)
+	}
+}
+
+func TestSize(t *testing.T) {
+	w := &wire{}
+	o := newOutbox(w)
+	o.Size = 2
+
+	o.Publish("a", []byte("1"))
+	o.Publish("b", []byte("1"))
+	// a newer payload for a queued topic takes no more room
+	o.Publish("b", []byte("2"))
+	if o.Queued() != 2 || o.Stats.Dropped != 1 {
+		t.Errorf("%d queued, %d dropped, want 2 and 1", o.Queued(), o.Stats.Dropped)
+	}
+	// a new topic pushes the oldest one out
+	o.Publish("c", []byte("1"))
+	if o.Queued() != 2 || o.Stats.Dropped != 2 {
+		t.Errorf("%d queued, %d dropped, want 2 and 2", o.Queued(), o.Stats.Dropped)
+	}
+	o.Flush()
+	expect(t, w, "b=2", "c=1")
+}
+
+func TestBudget(t *testing.T) {
+	w := &wire{}
+	o := newOutbox(w)
+	o.Budget = 2
+
+	for _, topic := range []string{"a", "b", "c", "d", "e"} {
+		o.Publish(topic, []byte("1"))
+	}
+	o.Flush()
+	expect(t, w, "a=1", "b=1")
+	o.Flush()
+	expect(t, w, "c=1", "d=1")
+	o.Flush()
+	expect(t, w, "e=1")
+
+	// topics waiting for their turn do not use it up
+	o.Rules["slow"] = Rule{Every: time.Second}
+	o.Publish("slow", []byte("1"))
+	o.Flush()
+	expect(t, w, "slow=1")
+	o.Publish("slow", []byte("2"))
+	o.Publish("f", []byte("1"))
+	o.Publish("g", []byte("1"))
+	o.Flush()
+	expect(t, w, "f=1", "g=1")
+}
+
+func TestFailed(t *testing.T) {
+	w := &wire{err: errors.New("no broker")}
+	o := newOutbox(w)
+	o.Default = Rule{Same: Equal}
+
+	o.Publish("a", []byte("1"))
+	o.Publish("b", []byte("1"))
+	o.Flush()
+	if o.Stats.Failed != 2 || o.Stats.Sent != 0 || o.Queued() != 0 {
+		t.Errorf("stats %+v, %d queued, want 2 failed and none queued", o.Stats, o.Queued())
+	}
+
+	// nothing went out, so the same payload is not skipped
+	w.err = nil
+	o.Publish("a", []byte("1"))
+	o.Flush()
+	expect(t, w, "a=1")
+	if o.Stats.Sent != 1 || o.Stats.Skipped != 0 {
+		t.Errorf("stats %+v, want 1 sent", o.Stats)
+	}
+}
+
+func TestReset(t *testing.T) {
+	w := &wire{}
+	o := newOutbox(w)
+	o.Default = Rule{Every: time.Second, Same: Equal}
+
+	o.Publish("a", []byte("1"))
+	o.Flush()
+	o.Publish("b", []byte("1"))
+	o.Publish("b", []byte("2"))
+	expect(t, w, "a=1")
+	o.Reset()
+	if o.Queued() != 0 {
+		t.Errorf("%d queued after Reset", o.Queued())
+	}
+
+	// a new session gets everything again, right away
+	o.Publish("a", []byte("1"))
+	o.Flush()
+	expect(t, w, "a=1")
+}
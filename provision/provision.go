@@ -0,0 +1,285 @@
+// Package provision parses the commands that set up the network of a
+// headset over its serial console, so it can join a new Wi-Fi network or
+// broker without a new firmware. It works without MQTT, which is the point.
+//
+// One command per line, case does not matter except in values:
+//
+//	SET <key> <value>  change a setting, value is the rest of the line; wrap
+//	                   it in double quotes to keep spaces at its ends
+//	CLEAR <key>        go back to the value the firmware was built with
+//	SHOW               list the settings, passwords hidden
+//	SAVE               store the settings and reconnect
+//	HELP               list the commands and keys
+//
+// The keys are SSID, PASSWORD, BROKER (host or host:port), PORT, USER,
+// MQTTPASSWORD, DEVICE, WIFI (ON or OFF) and MQTT (ON or OFF).
+package provision
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/conejoninja/vision/settings"
+	"github.com/conejoninja/vision/topic"
+)
+
+// Op is what a command does.
+type Op uint8
+
+const (
+	Set Op = iota + 1
+	Clear
+	Show
+	Save
+	Help
+)
+
+// Key is the setting a command is about.
+type Key uint8
+
+const (
+	SSID Key = iota + 1
+	Password
+	Broker
+	Port
+	User
+	MQTTPassword
+	Device
+	Wifi
+	MQTT
+)
+
+var keys = []struct {
+	name string
+	key  Key
+}{
+	{"SSID", SSID},
+	{"PASSWORD", Password},
+	{"BROKER", Broker},
+	{"PORT", Port},
+	{"USER", User},
+	{"MQTTPASSWORD", MQTTPassword},
+	{"DEVICE", Device},
+	{"WIFI", Wifi},
+	{"MQTT", MQTT},
+}
+
+func (k Key) String() string {
+	for _, n := range keys {
+		if n.key == k {
+			return n.name
+		}
+	}
+	return "?"
+}
+
+var (
+	ErrEmpty   = errors.New("provision: empty line")
+	ErrUnknown = errors.New("provision: unknown command")
+	ErrKey     = errors.New("provision: unknown key")
+	ErrArgs    = errors.New("provision: wrong arguments")
+	ErrValue   = errors.New("provision: bad value")
+)
+
+// Command is a parsed command.
+type Command struct {
+	Op    Op
+	Key   Key
+	Value string
+}
+
+// Parse reads a command from a line.
+func Parse(line string) (Command, error) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return Command{}, ErrEmpty
+	}
+	word, rest := cut(line)
+	var c Command
+	switch strings.ToUpper(word) {
+	case "SET":
+		name, value := cut(rest)
+		if name == "" || value == "" {
+			return c, ErrArgs
+		}
+		k, err := parseKey(name)
+		if err != nil {
+			return c, err
+		}
+		if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+			value = value[1 : len(value)-1]
+		}
+		c = Command{Op: Set, Key: k, Value: value}
+	case "CLEAR":
+		if rest == "" || strings.ContainsRune(rest, ' ') {
+			return c, ErrArgs
+		}
+		k, err := parseKey(rest)
+		if err != nil {
+			return c, err
+		}
+		c = Command{Op: Clear, Key: k}
+	case "SHOW":
+		c.Op = Show
+	case "SAVE":
+		c.Op = Save
+	case "HELP", "?":
+		c.Op = Help
+	default:
+		return c, ErrUnknown
+	}
+	if c.Op != Set && c.Op != Clear && rest != "" {
+		return Command{}, ErrArgs
+	}
+	return c, nil
+}
+
+// cut splits the first word off s.
+func cut(s string) (word, rest string) {
+	word, rest, _ = strings.Cut(s, " ")
+	return word, strings.TrimSpace(rest)
+}
+
+func parseKey(name string) (Key, error) {
+	name = strings.ToUpper(name)
+	for _, n := range keys {
+		if n.name == name {
+			return n.key, nil
+		}
+	}
+	return 0, ErrKey
+}
+
+// Apply makes a Set or Clear command change s, after checking the value.
+func Apply(s *settings.Settings, c Command) error {
+	v := c.Value
+	if c.Op == Clear {
+		v = ""
+	} else if c.Op != Set {
+		return ErrArgs
+	}
+	if len(v) > settings.MaxString {
+		return ErrValue
+	}
+	switch c.Key {
+	case SSID:
+		// 802.11 limits SSIDs to 32 bytes
+		if len(v) > 32 {
+			return ErrValue
+		}
+		s.WifiSSID = v
+	case Password:
+		s.WifiPassword = v
+	case Broker:
+		host, port, found := strings.Cut(v, ":")
+		if found {
+			p, err := parsePort(port)
+			if err != nil || host == "" {
+				return ErrValue
+			}
+			s.MQTTPort = p
+		}
+		s.MQTTServer = host
+	case Port:
+		if v == "" {
+			s.MQTTPort = 0
+			break
+		}
+		p, err := parsePort(v)
+		if err != nil {
+			return err
+		}
+		s.MQTTPort = p
+	case User:
+		s.MQTTUser = v
+	case MQTTPassword:
+		s.MQTTPassword = v
+	case Device:
+		if v != "" && !topic.ValidDevice(v) {
+			return ErrValue
+		}
+		s.DeviceID = v
+	case Wifi, MQTT:
+		on, err := parseOnOff(v, c.Key == MQTT)
+		if err != nil {
+			return err
+		}
+		if c.Key == Wifi {
+			s.WifiEnabled = on
+		} else {
+			s.MQTTEnabled = on
+		}
+	default:
+		return ErrKey
+	}
+	return nil
+}
+
+func parsePort(v string) (uint16, error) {
+	p, err := strconv.ParseUint(v, 10, 16)
+	if err != nil || p == 0 {
+		return 0, ErrValue
+	}
+	return uint16(p), nil
+}
+
+// parseOnOff reads ON or OFF, empty is the default def.
+func parseOnOff(v string, def bool) (bool, error) {
+	switch strings.ToUpper(v) {
+	case "":
+		return def, nil
+	case "ON":
+		return true, nil
+	case "OFF":
+		return false, nil
+	}
+	return false, ErrValue
+}
+
+// List lists the network settings in s, one per line, with the passwords
+// hidden. Empty values are shown as "-", they mean the built-in default.
+func List(s *settings.Settings) []string {
+	port := "-"
+	if s.MQTTPort != 0 {
+		port = strconv.Itoa(int(s.MQTTPort))
+	}
+	return []string{
+		"SSID " + orDash(s.WifiSSID),
+		"PASSWORD " + hidden(s.WifiPassword),
+		"BROKER " + orDash(s.MQTTServer),
+		"PORT " + port,
+		"USER " + orDash(s.MQTTUser),
+		"MQTTPASSWORD " + hidden(s.MQTTPassword),
+		"DEVICE " + orDash(s.DeviceID),
+		"WIFI " + onOff(s.WifiEnabled),
+		"MQTT " + onOff(s.MQTTEnabled),
+	}
+}
+
+// HelpText lists the commands and keys.
+var HelpText = []string{
+	"SET <key> <value>, CLEAR <key>, SHOW, SAVE, HELP",
+	"keys: SSID PASSWORD BROKER PORT USER MQTTPASSWORD DEVICE WIFI MQTT",
+}
+
+func orDash(v string) string {
+	if v == "" {
+		return "-"
+	}
+	return v
+}
+
+func hidden(v string) string {
+	if v == "" {
+		return "-"
+	}
+	return "****"
+}
+
+func onOff(b bool) string {
+	if b {
+		return "ON"
+	}
+	return "OFF"
+}
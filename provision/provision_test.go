@@ -0,0 +1,128 @@
+package provision
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/conejoninja/vision/settings"
+)
+
+func TestParse(t *testing.T) {
+	for _, tt := range []struct {
+		line string
+		want Command
+		err  error
+	}{
+		{"", Command{}, ErrEmpty},
+		{"   ", Command{}, ErrEmpty},
+		{"SET SSID home", Command{Set, SSID, "home"}, nil},
+		{"set ssid home", Command{Set, SSID, "home"}, nil},
+		// the value is the rest of the line, case and inner spaces kept
+		{"SET PASSWORD Open  Sesame", Command{Set, Password, "Open  Sesame"}, nil},
+		{`SET PASSWORD " padded "`, Command{Set, Password, " padded "}, nil},
+		{`SET PASSWORD "`, Command{Set, Password, `"`}, nil},
+		{"  SET   BROKER   mqtt.local:8883  ", Command{Set, Broker, "mqtt.local:8883"}, nil},
+		{"SET SSID", Command{}, ErrArgs},
+		{"SET", Command{}, ErrArgs},
+		{"SET COLOR red", Command{}, ErrKey},
+		{"CLEAR device", Command{Clear, Device, ""}, nil},
+		{"CLEAR", Command{}, ErrArgs},
+		{"CLEAR SSID PASSWORD", Command{}, ErrArgs},
+		{"CLEAR NOPE", Command{}, ErrKey},
+		{"SHOW", Command{Op: Show}, nil},
+		{"show all", Command{}, ErrArgs},
+		{"save", Command{Op: Save}, nil},
+		{"HELP", Command{Op: Help}, nil},
+		{"?", Command{Op: Help}, nil},
+		{"REBOOT", Command{}, ErrUnknown},
+	} {
+		got, err := Parse(tt.line)
+		if err != tt.err || got != tt.want {
+			t.Errorf("Parse(%q) = %+v, %v, want %+v, %v", tt.line, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	for _, tt := range []struct {
+		line   string
+		change func(s *settings.Settings)
+		err    error
+	}{
+		{"SET SSID home", func(s *settings.Settings) { s.WifiSSID = "home" }, nil},
+		{"SET SSID " + strings.Repeat("x", 33), nil, ErrValue},
+		{"SET PASSWORD " + strings.Repeat("x", settings.MaxString+1), nil, ErrValue},
+		{"SET BROKER mqtt.local", func(s *settings.Settings) { s.MQTTServer = "mqtt.local" }, nil},
+		{"SET BROKER mqtt.local:8883", func(s *settings.Settings) {
+			s.MQTTServer = "mqtt.local"
+			s.MQTTPort = 8883
+		}, nil},
+		{"SET BROKER :8883", nil, ErrValue},
+		{"SET BROKER mqtt.local:0", nil, ErrValue},
+		{"SET BROKER mqtt.local:99999", nil, ErrValue},
+		{"SET PORT 1884", func(s *settings.Settings) { s.MQTTPort = 1884 }, nil},
+		{"SET PORT http", nil, ErrValue},
+		{"CLEAR PORT", func(s *settings.Settings) { s.MQTTPort = 0 }, nil},
+		{"SET USER gopher", func(s *settings.Settings) { s.MQTTUser = "gopher" }, nil},
+		{"SET MQTTPASSWORD hunter2", func(s *settings.Settings) { s.MQTTPassword = "hunter2" }, nil},
+		{"SET DEVICE vision7", func(s *settings.Settings) { s.DeviceID = "vision7" }, nil},
+		{"SET DEVICE vision/7", nil, ErrValue},
+		{"SET DEVICE vision+", nil, ErrValue},
+		{"CLEAR DEVICE", func(s *settings.Settings) { s.DeviceID = "" }, nil},
+		{"SET WIFI off", func(s *settings.Settings) { s.WifiEnabled = false }, nil},
+		{"SET MQTT ON", func(s *settings.Settings) { s.MQTTEnabled = true }, nil},
+		{"SET MQTT maybe", nil, ErrValue},
+		// clearing the switches goes back to Wi-Fi off and MQTT on
+		{"CLEAR WIFI", func(s *settings.Settings) { s.WifiEnabled = false }, nil},
+		{"CLEAR MQTT", func(s *settings.Settings) { s.MQTTEnabled = true }, nil},
+	} {
+		c, err := Parse(tt.line)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tt.line, err)
+		}
+		start := settings.Defaults()
+		start.WifiEnabled = true
+		start.MQTTEnabled = false
+		start.DeviceID = "old"
+		got := start
+		err = Apply(&got, c)
+		want := start
+		if tt.change != nil {
+			tt.change(&want)
+		}
+		if err != tt.err {
+			t.Errorf("%s: error %v, want %v", tt.line, err, tt.err)
+		}
+		if got != want {
+			t.Errorf("%s: got %+v, want %+v", tt.line, got, want)
+		}
+	}
+
+	s := settings.Defaults()
+	if err := Apply(&s, Command{Op: Show}); err != ErrArgs {
+		t.Errorf("applying SHOW: %v", err)
+	}
+}
+
+func TestList(t *testing.T) {
+	s := settings.Defaults()
+	s.WifiSSID = "home"
+	s.WifiPassword = "secret"
+	s.MQTTPort = 8883
+	s.WifiEnabled = true
+	want := []string{
+		"SSID home",
+		"PASSWORD ****",
+		"BROKER -",
+		"PORT 8883",
+		"USER -",
+		"MQTTPASSWORD -",
+		"DEVICE -",
+		"WIFI ON",
+		"MQTT " + onOff(s.MQTTEnabled),
+	}
+	if got := List(&s); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
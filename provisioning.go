@@ -0,0 +1,62 @@
+package main
+
+import (
+	"github.com/conejoninja/vision/provision"
+	"github.com/conejoninja/vision/settings"
+)
+
+// provisionEdits holds the changes typed on the console until SAVE. They
+// are applied again on top of the settings of the moment, so whatever the
+// menu changed in between is kept.
+var provisionEdits []provision.Command
+
+// provisionLine runs a provisioning command and returns the reply.
+func provisionLine(line string) []string {
+	c, err := provision.Parse(line)
+	if err == provision.ErrEmpty {
+		return nil
+	}
+	if err != nil {
+		return []string{err.Error()}
+	}
+	switch c.Op {
+	case provision.Set, provision.Clear:
+		s := pendingSettings()
+		if err := provision.Apply(&s, c); err != nil {
+			return []string{c.Key.String() + ": " + err.Error()}
+		}
+		provisionEdits = append(provisionEdits, c)
+		return []string{"OK, SAVE to keep it"}
+	case provision.Show:
+		s := pendingSettings()
+		return provision.List(&s)
+	case provision.Save:
+		saveProvisioning()
+		return []string{"SAVED"}
+	case provision.Help:
+		return provision.HelpText
+	}
+	return nil
+}
+
+// pendingSettings returns the current settings with the changes typed so
+// far.
+func pendingSettings() settings.Settings {
+	s := currentSettings()
+	for _, c := range provisionEdits {
+		// every edit was checked when it was typed
+		provision.Apply(&s, c)
+	}
+	return s
+}
+
+// saveProvisioning stores the provisioned settings and reconnects with
+// them.
+func saveProvisioning() {
+	s := pendingSettings()
+	provisionEdits = provisionEdits[:0]
+	useSettings(&s)
+	saveSettings()
+	network.Stop()
+	connect()
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/conejoninja/vision/settings"
+)
+
+func TestProvisioningKeepsOtherChanges(t *testing.T) {
+	newTestBoard(t, NORTH)
+	defer func() { provisionEdits = nil }()
+
+	provisionLine("SET SSID home")
+	// changed from the menu while provisioning
+	brightness = 30
+	setWifi(true)
+	offsetHeading = 12
+	provisionLine("SET USER gopher")
+	provisionLine("SAVE")
+	defer setWifi(false)
+
+	s, err := settings.Load(storage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.WifiSSID != "home" || s.MQTTUser != "gopher" {
+		t.Errorf("network settings %q %q not saved", s.WifiSSID, s.MQTTUser)
+	}
+	if s.Brightness != 30 || !s.WifiEnabled || s.OffsetHeading != 12 {
+		t.Errorf("lost the changes made meanwhile: %+v", s)
+	}
+}
+
+func TestProvisioningShowsPendingChanges(t *testing.T) {
+	newTestBoard(t, NORTH)
+	defer func() { provisionEdits = nil }()
+
+	if got := provisionLine("SET PORT none"); len(got) != 1 || got[0] == "OK, SAVE to keep it" {
+		t.Errorf("bad port accepted: %q", got)
+	}
+	provisionLine("SET BROKER mqtt.local:8883")
+	provisionLine("CLEAR PORT")
+	got := provisionLine("SHOW")
+	if got[2] != "BROKER mqtt.local" || got[3] != "PORT -" {
+		t.Errorf("SHOW %q", got)
+	}
+	if stored.MQTTServer != "" {
+		t.Errorf("saved %q before SAVE", stored.MQTTServer)
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"time"
+
+	"github.com/conejoninja/vision/link"
+	"github.com/conejoninja/vision/outbox"
+	"github.com/conejoninja/vision/telemetry"
+)
+
+// outgoing holds everything published from the game loop until the link
+// can take it, see outboxRules for how often each topic goes out.
+var outgoing = outbox.Outbox{
+	Send:   network.Publish,
+	Size:   16,
+	Budget: 8,
+}
+
+// outboxRules limits the topics that would otherwise go out every frame.
+// Everything else is sent as it comes.
+func outboxRules() map[string]outbox.Rule {
+	const keep = 5 * time.Second
+	changes := outbox.Rule{Every: 100 * time.Millisecond, Same: outbox.Equal, Keep: keep}
+	return map[string]outbox.Rule{
+		telemetryTopic:          {Every: 100 * time.Millisecond, Same: telemetry.Deadband(5), Keep: 2 * time.Second},
+		orientationTopic:        changes,
+		ledsTopic:               changes,
+		pitchTopic:              {Every: 200 * time.Millisecond, Same: outbox.Deadband(2), Keep: keep},
+		rollTopic:               {Every: 200 * time.Millisecond, Same: outbox.Deadband(2), Keep: keep},
+		circlesArcTopic:         changes,
+		circlesOrientationTopic: changes,
+		circlesRadiusTopic:      {Every: 100 * time.Millisecond, Same: outbox.Equal, Keep: keep},
+		mazeTopic:               changes,
+	}
+}
+
+// flushOutbox sends what is due this frame.
+func flushOutbox(dt time.Duration) {
+	outgoing.Update(dt)
+	if network.State() == link.Up {
+		outgoing.Flush()
+	}
+}
@@ -0,0 +1,44 @@
+// Package queue passes values from the goroutine that receives them, like
+// the MQTT reader, to the game loop.
+package queue
+
+// Queue is a bounded queue where neither side ever waits, values that do
+// not fit are dropped.
+type Queue[T any] struct {
+	ch chan T
+}
+
+// New makes a queue that holds up to n values.
+func New[T any](n int) *Queue[T] {
+	return &Queue[T]{ch: make(chan T, n)}
+}
+
+// Push adds v to the queue, it reports false if the queue was full.
+func (q *Queue[T]) Push(v T) bool {
+	select {
+	case q.ch <- v:
+		return true
+	default:
+		return false
+	}
+}
+
+// Pop takes the oldest value, if there is one.
+func (q *Queue[T]) Pop() (T, bool) {
+	select {
+	case v := <-q.ch:
+		return v, true
+	default:
+		var zero T
+		return zero, false
+	}
+}
+
+// Clear throws away everything queued so far.
+func (q *Queue[T]) Clear() {
+	for {
+		if _, ok := q.Pop(); !ok {
+			return
+		}
+	}
+}
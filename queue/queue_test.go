@@ -0,0 +1,28 @@
+package queue
+
+import "testing"
+
+func TestQueue(t *testing.T) {
+	q := New[int](2)
+	if _, ok := q.Pop(); ok {
+		t.Fatal("popped from an empty queue")
+	}
+	if !q.Push(1) || !q.Push(2) {
+		t.Fatal("push refused with room left")
+	}
+	if q.Push(3) {
+		t.Error("push accepted on a full queue")
+	}
+	for _, want := range []int{1, 2} {
+		if v, ok := q.Pop(); !ok || v != want {
+			t.Errorf("popped %d, %v, want %d", v, ok, want)
+		}
+	}
+
+	q.Push(4)
+	q.Push(5)
+	q.Clear()
+	if v, ok := q.Pop(); ok {
+		t.Errorf("popped %d after Clear", v)
+	}
+}
@@ -0,0 +1,318 @@
+// Package race lets several headsets play the same maze and race to its
+// exit over MQTT.
+//
+// Every headset publishes small messages on its own race topic and
+// subscribes to the race topic of every other one. A race is identified by
+// the seed and size of the generated maze, so everyone builds the same
+// level. The protocol is:
+//
+//   - Start: the sender starts a new race, everyone racing switches to its
+//     maze and goes back to the start.
+//   - Join: the sender wants to race, whoever is racing answers with a State
+//     right away.
+//   - State: where the sender is, sent a few times per second. A headset
+//     that just joined follows the maze of the first State it hears. Two
+//     headsets racing on their own both end up on the maze of the one with
+//     the lowest device ID.
+//   - Finish: the sender reached the exit. The first Finish of a race the
+//     broker delivers wins it, every headset sees the same order.
+//   - Leave: the sender stops racing. Headsets that go quiet for Timeout
+//     leave on their own.
+package race
+
+import (
+	"encoding/binary"
+	"errors"
+	"time"
+)
+
+// Kind of message.
+type Kind uint8
+
+const (
+	Start Kind = iota + 1
+	Join
+	State
+	Finish
+	Leave
+)
+
+// Version of the messages written by Append.
+const Version = 1
+
+// MessageSize is the size of an encoded message.
+const MessageSize = 21
+
+var (
+	ErrMagic   = errors.New("race: not a race message")
+	ErrVersion = errors.New("race: unknown version")
+	ErrShort   = errors.New("race: message too short")
+)
+
+// Message is what headsets send each other. Encoded it is 21 bytes, big
+// endian:
+//
+//	0   2  magic "VR"
+//	2   1  version, 1
+//	3   1  kind
+//	4   4  seed of the maze, uint32
+//	8   1  size of the maze
+//	9   4  x, int32, in maze units
+//	13  4  y, int32
+//	17  4  time since the race started, milliseconds, uint32
+type Message struct {
+	Kind    Kind
+	Seed    uint32
+	Size    uint8
+	X, Y    int32
+	Elapsed uint32
+}
+
+// Append encodes m at the end of b.
+func (m Message) Append(b []byte) []byte {
+	b = append(b, 'V', 'R', Version, byte(m.Kind))
+	b = binary.BigEndian.AppendUint32(b, m.Seed)
+	b = append(b, m.Size)
+	b = binary.BigEndian.AppendUint32(b, uint32(m.X))
+	b = binary.BigEndian.AppendUint32(b, uint32(m.Y))
+	return binary.BigEndian.AppendUint32(b, m.Elapsed)
+}
+
+// Decode reads a message from b, anything after it is ignored. Messages of
+// any other version are refused, a race needs everyone to agree on them.
+func Decode(b []byte) (Message, error) {
+	if len(b) < 3 {
+		return Message{}, ErrShort
+	}
+	if b[0] != 'V' || b[1] != 'R' {
+		return Message{}, ErrMagic
+	}
+	if b[2] != Version {
+		return Message{}, ErrVersion
+	}
+	if len(b) < MessageSize {
+		return Message{}, ErrShort
+	}
+	return Message{
+		Kind:    Kind(b[3]),
+		Seed:    binary.BigEndian.Uint32(b[4:]),
+		Size:    b[8],
+		X:       int32(binary.BigEndian.Uint32(b[9:])),
+		Y:       int32(binary.BigEndian.Uint32(b[13:])),
+		Elapsed: binary.BigEndian.Uint32(b[17:]),
+	}, nil
+}
+
+// Peer is another headset in the race.
+type Peer struct {
+	Device string
+	// Seed is the maze the peer is on, it may not be the one of the race
+	// for a moment after a Start.
+	Seed uint32
+	X, Y int32
+	// Finished is set once the peer reached the exit.
+	Finished bool
+	// Slot is the lowest number no other peer had when this one was first
+	// heard from. It is kept while the peer is in the race, so it can tell
+	// the peer apart, by colour say, when others come and go.
+	Slot int
+
+	quiet time.Duration
+}
+
+// EventKind says what changed in the race.
+type EventKind uint8
+
+const (
+	None EventKind = iota
+	// Started means the race is on a new maze, build it and go back to the
+	// start.
+	Started
+	Joined
+	Left
+	// Won means Device won the race, it may be Self.
+	Won
+	// Asked means somebody joined and wants to hear from everyone.
+	Asked
+)
+
+// Event is something that happened to the race.
+type Event struct {
+	Kind   EventKind
+	Device string
+}
+
+// Race keeps track of a race from the point of view of one headset. It is
+// not safe for concurrent use, messages are handed to it from the game
+// loop.
+type Race struct {
+	// Self is the device ID of this headset.
+	Self string
+	// Timeout is how long a peer may stay quiet before it is dropped.
+	Timeout time.Duration
+
+	Seed uint32
+	Size uint8
+	// Winner is who won the current race, empty while it is running.
+	Winner string
+	Peers  []Peer
+
+	joining bool
+}
+
+// Join marks this headset as looking for a race, it follows the next State
+// it hears. The Join message is up to the caller.
+func (r *Race) Join() {
+	r.joining = true
+}
+
+// Reset starts a race on a new maze, without telling anyone. The Start
+// message is up to the caller.
+func (r *Race) Reset(seed uint32, size uint8) {
+	r.Seed, r.Size = seed, size
+	r.joining = false
+	r.Winner = ""
+	for i := range r.Peers {
+		r.Peers[i].Finished = false
+	}
+}
+
+// Clear forgets every peer, when leaving the race.
+func (r *Race) Clear() {
+	r.Peers = r.Peers[:0]
+	r.Winner = ""
+	r.joining = false
+}
+
+// Receive handles a message device sent, including the ones this headset
+// sent itself, which the broker sends back.
+func (r *Race) Receive(device string, m Message) Event {
+	self := device == r.Self
+	switch m.Kind {
+	case Start:
+		if self {
+			return Event{}
+		}
+		r.Reset(m.Seed, m.Size)
+		r.seen(device, m)
+		return Event{Kind: Started, Device: device}
+	case Join:
+		if self {
+			return Event{}
+		}
+		r.seen(device, m)
+		return Event{Kind: Asked, Device: device}
+	case State:
+		if self {
+			return Event{}
+		}
+		if m.Seed != r.Seed && (r.joining || r.racers() == 0 && device < r.Self) {
+			// join the race this peer is in
+			r.Reset(m.Seed, m.Size)
+			r.seen(device, m)
+			return Event{Kind: Started, Device: device}
+		}
+		if r.seen(device, m) {
+			return Event{Kind: Joined, Device: device}
+		}
+	case Finish:
+		if m.Seed != r.Seed {
+			return Event{}
+		}
+		if !self {
+			r.seen(device, m)
+			if p := r.peer(device); p != nil {
+				p.Finished = true
+			}
+		}
+		if r.Winner == "" {
+			r.Winner = device
+			return Event{Kind: Won, Device: device}
+		}
+	case Leave:
+		if !self && r.remove(device) {
+			return Event{Kind: Left, Device: device}
+		}
+	}
+	return Event{}
+}
+
+// Update advances time by dt and returns the peers that timed out.
+func (r *Race) Update(dt time.Duration) []string {
+	var gone []string
+	for i := 0; i < len(r.Peers); i++ {
+		r.Peers[i].quiet += dt
+		if r.Timeout > 0 && r.Peers[i].quiet > r.Timeout {
+			gone = append(gone, r.Peers[i].Device)
+			r.Peers = append(r.Peers[:i], r.Peers[i+1:]...)
+			i--
+		}
+	}
+	return gone
+}
+
+// seen records a message from device and reports whether it is new.
+func (r *Race) seen(device string, m Message) bool {
+	p := r.peer(device)
+	isNew := p == nil
+	if isNew {
+		r.Peers = append(r.Peers, Peer{Device: device, Slot: r.freeSlot()})
+		p = &r.Peers[len(r.Peers)-1]
+	}
+	p.quiet = 0
+	switch m.Kind {
+	case Start:
+		p.Seed = m.Seed
+		p.X, p.Y = 0, 0
+	case State, Finish:
+		p.Seed = m.Seed
+		p.X, p.Y = m.X, m.Y
+	}
+	return isNew
+}
+
+func (r *Race) peer(device string) *Peer {
+	for i := range r.Peers {
+		if r.Peers[i].Device == device {
+			return &r.Peers[i]
+		}
+	}
+	return nil
+}
+
+// freeSlot returns the lowest slot no peer has.
+func (r *Race) freeSlot() int {
+	for slot := 0; ; slot++ {
+		taken := false
+		for i := range r.Peers {
+			if r.Peers[i].Slot == slot {
+				taken = true
+				break
+			}
+		}
+		if !taken {
+			return slot
+		}
+	}
+}
+
+func (r *Race) remove(device string) bool {
+	for i := range r.Peers {
+		if r.Peers[i].Device == device {
+			r.Peers = append(r.Peers[:i], r.Peers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// racers counts the peers on the maze of the race.
+func (r *Race) racers() int {
+	n := 0
+	for i := range r.Peers {
+		if r.Peers[i].Seed == r.Seed {
+			n++
+		}
+	}
+	return n
+}
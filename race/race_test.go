@@ -0,0 +1,200 @@
+package race
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMessageRoundTrip(t *testing.T) {
+	m := Message{Kind: Finish, Seed: 1234, Size: 21, X: 450, Y: -1, Elapsed: 65000}
+	b := m.Append(nil)
+	if len(b) != MessageSize {
+		t.Fatalf("encoded in %d bytes, want %d", len(b), MessageSize)
+	}
+	got, err := Decode(append(b, 0xff))
+	if err != nil || got != m {
+		t.Errorf("got %+v, %v, want %+v", got, err, m)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	good := Message{Kind: State, Seed: 1}.Append(nil)
+	version := func(v byte) []byte {
+		b := append([]byte(nil), good...)
+		b[2] = v
+		return b
+	}
+	for _, tt := range []struct {
+		name string
+		b    []byte
+		err  error
+	}{
+		{"empty", nil, ErrShort},
+		{"not a race message", []byte("VT\x01"), ErrMagic},
+		{"version 0", version(0), ErrVersion},
+		{"later version", version(Version + 1), ErrVersion},
+		{"cut", good[:MessageSize-1], ErrShort},
+	} {
+		if _, err := Decode(tt.b); err != tt.err {
+			t.Errorf("%s: error %v, want %v", tt.name, err, tt.err)
+		}
+	}
+}
+
+// newRace returns the race of headset "m", on maze 1.
+func newRace() *Race {
+	r := &Race{Self: "m", Timeout: time.Second}
+	r.Reset(1, 21)
+	return r
+}
+
+// receive fails unless r turns m from device into want.
+func receive(t *testing.T, r *Race, device string, m Message, want Event) {
+	t.Helper()
+	if got := r.Receive(device, m); got != want {
+		t.Errorf("%s %+v: got %+v, want %+v", device, m, got, want)
+	}
+}
+
+func TestJoinFollowsTheRace(t *testing.T) {
+	r := newRace()
+	r.Join()
+	// whoever answers first has the maze of the race
+	receive(t, r, "z", Message{Kind: State, Seed: 7, Size: 31, X: 3, Y: 4}, Event{Started, "z"})
+	if r.Seed != 7 || r.Size != 31 {
+		t.Errorf("on maze %d/%d, want 7/31", r.Seed, r.Size)
+	}
+	// and then it stops following
+	receive(t, r, "y", Message{Kind: State, Seed: 8, Size: 21}, Event{Joined, "y"})
+	if r.Seed != 7 {
+		t.Errorf("followed %d after joining", r.Seed)
+	}
+	if p := r.peer("z"); p == nil || p.X != 3 || p.Y != 4 {
+		t.Errorf("peer z %+v", p)
+	}
+}
+
+func TestMergeOnTheLowestID(t *testing.T) {
+	// m racing on its own hears from z, which has the higher ID
+	r := newRace()
+	receive(t, r, "z", Message{Kind: State, Seed: 9, Size: 21}, Event{Joined, "z"})
+	if r.Seed != 1 {
+		t.Errorf("followed the higher ID to %d", r.Seed)
+	}
+
+	// and from a, with the lower one
+	r = newRace()
+	receive(t, r, "a", Message{Kind: State, Seed: 9, Size: 25}, Event{Started, "a"})
+	if r.Seed != 9 || r.Size != 25 {
+		t.Errorf("on maze %d/%d, want a's 9/25", r.Seed, r.Size)
+	}
+
+	// someone racing with m is not left behind for a lower ID
+	r = newRace()
+	receive(t, r, "z", Message{Kind: State, Seed: 1}, Event{Joined, "z"})
+	receive(t, r, "a", Message{Kind: State, Seed: 9}, Event{Joined, "a"})
+	if r.Seed != 1 {
+		t.Errorf("left the race for %d", r.Seed)
+	}
+}
+
+func TestStart(t *testing.T) {
+	r := newRace()
+	receive(t, r, "z", Message{Kind: State, Seed: 1, X: 5}, Event{Joined, "z"})
+	receive(t, r, "z", Message{Kind: Finish, Seed: 1}, Event{Won, "z"})
+	receive(t, r, "y", Message{Kind: Start, Seed: 2, Size: 15}, Event{Started, "y"})
+	if r.Seed != 2 || r.Size != 15 || r.Winner != "" {
+		t.Errorf("after Start: maze %d/%d, winner %q", r.Seed, r.Size, r.Winner)
+	}
+	if p := r.peer("z"); p.Finished {
+		t.Error("z is still finished in the new race")
+	}
+	if p := r.peer("y"); p == nil || p.Seed != 2 || p.X != 0 {
+		t.Errorf("peer y %+v", p)
+	}
+	// the broker sends back what this headset sent
+	receive(t, r, "m", Message{Kind: Start, Seed: 3}, Event{})
+	if r.Seed != 2 {
+		t.Errorf("own Start moved the race to %d", r.Seed)
+	}
+	receive(t, r, "q", Message{Kind: Join}, Event{Asked, "q"})
+	receive(t, r, "m", Message{Kind: Join}, Event{})
+}
+
+func TestFirstFinishWins(t *testing.T) {
+	r := newRace()
+	receive(t, r, "z", Message{Kind: State, Seed: 1}, Event{Joined, "z"})
+	// a Finish from another maze does not count
+	receive(t, r, "y", Message{Kind: Finish, Seed: 2}, Event{})
+	receive(t, r, "m", Message{Kind: Finish, Seed: 1}, Event{Won, "m"})
+	receive(t, r, "z", Message{Kind: Finish, Seed: 1}, Event{})
+	if r.Winner != "m" {
+		t.Errorf("winner %q, want m", r.Winner)
+	}
+	if p := r.peer("z"); !p.Finished {
+		t.Error("z finished but is not marked so")
+	}
+	if r.peer("m") != nil {
+		t.Error("this headset is one of its peers")
+	}
+}
+
+func TestRepeatsAreNotNew(t *testing.T) {
+	r := newRace()
+	receive(t, r, "z", Message{Kind: State, Seed: 1, X: 1}, Event{Joined, "z"})
+	receive(t, r, "z", Message{Kind: State, Seed: 1, X: 2}, Event{})
+	receive(t, r, "z", Message{Kind: Join}, Event{Asked, "z"})
+	receive(t, r, "m", Message{Kind: State, Seed: 1}, Event{})
+	if len(r.Peers) != 1 || r.Peers[0].X != 2 {
+		t.Errorf("peers %+v, want z at x 2", r.Peers)
+	}
+	receive(t, r, "z", Message{Kind: Leave}, Event{Left, "z"})
+	receive(t, r, "z", Message{Kind: Leave}, Event{})
+	if len(r.Peers) != 0 {
+		t.Errorf("peers %+v after leaving", r.Peers)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	r := newRace()
+	receive(t, r, "y", Message{Kind: State, Seed: 1}, Event{Joined, "y"})
+	receive(t, r, "z", Message{Kind: State, Seed: 1}, Event{Joined, "z"})
+	if gone := r.Update(600 * time.Millisecond); gone != nil {
+		t.Errorf("%v gone early", gone)
+	}
+	// z keeps talking, y does not
+	receive(t, r, "z", Message{Kind: State, Seed: 1}, Event{})
+	gone := r.Update(600 * time.Millisecond)
+	if len(gone) != 1 || gone[0] != "y" {
+		t.Errorf("gone %v, want [y]", gone)
+	}
+	if len(r.Peers) != 1 || r.Peers[0].Device != "z" {
+		t.Errorf("peers %+v, want z", r.Peers)
+	}
+	// and hearing from y again brings it back
+	receive(t, r, "y", Message{Kind: State, Seed: 1}, Event{Joined, "y"})
+}
+
+func TestSlots(t *testing.T) {
+	r := newRace()
+	for _, d := range []string{"a", "b", "c"} {
+		r.Receive(d, Message{Kind: State, Seed: 1})
+	}
+	slot := func(d string) int { return r.peer(d).Slot }
+	if slot("a") != 0 || slot("b") != 1 || slot("c") != 2 {
+		t.Fatalf("slots %+v", r.Peers)
+	}
+	// the others keep theirs when one leaves, and the next takes its place
+	r.Receive("a", Message{Kind: Leave})
+	if slot("b") != 1 || slot("c") != 2 {
+		t.Errorf("slots moved when a left: %+v", r.Peers)
+	}
+	r.Receive("d", Message{Kind: State, Seed: 1})
+	if slot("d") != 0 {
+		t.Errorf("d got slot %d, want a's 0", slot("d"))
+	}
+	r.Receive("e", Message{Kind: State, Seed: 1})
+	if slot("e") != 3 {
+		t.Errorf("e got slot %d, want 3", slot("e"))
+	}
+}
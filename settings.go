@@ -0,0 +1,99 @@
+package main
+
+import (
+	"strconv"
+
+	"github.com/conejoninja/vision/logging"
+	"github.com/conejoninja/vision/settings"
+)
+
+var (
+	// storage is where the settings are kept, set up by setupBoard.
+	storage settings.Storage
+	// stored is what was last loaded or saved, the network fields are only
+	// changed by provisioning.
+	stored = settings.Defaults()
+	// builtinDeviceID is the DeviceID the firmware was built with.
+	builtinDeviceID = DeviceID
+)
+
+// loadSettings restores what was saved by saveSettings, falling back to the
+// defaults when nothing valid is stored.
+func loadSettings() {
+	s, err := settings.Load(storage)
+	if err != nil && settingsLog.Enabled(logging.Warn) {
+		settingsLog.Warn(err.Error())
+	}
+	useSettings(&s)
+}
+
+// useSettings makes s the current settings.
+func useSettings(s *settings.Settings) {
+	stored = *s
+	offsetHeading = int(s.OffsetHeading)
+	offsetHeadingRads = s.OffsetHeadingRads
+	calibration.Min = s.MagMin
+	calibration.Max = s.MagMax
+	brightness = int(s.Brightness)
+	useWifi = s.WifiEnabled
+	useMQTT = s.MQTTEnabled
+	DeviceID = or(s.DeviceID, builtinDeviceID)
+	setTopics(DeviceID)
+}
+
+// currentSettings returns the settings as they are now.
+func currentSettings() settings.Settings {
+	s := stored
+	s.OffsetHeading = int32(offsetHeading)
+	s.OffsetHeadingRads = offsetHeadingRads
+	s.MagMin = calibration.Min
+	s.MagMax = calibration.Max
+	s.Brightness = uint8(brightness)
+	s.WifiEnabled = useWifi
+	s.MQTTEnabled = useMQTT
+	return s
+}
+
+func saveSettings() {
+	s := currentSettings()
+	if err := settings.Save(storage, &s); err != nil && settingsLog.Enabled(logging.Error) {
+		settingsLog.Error("save failed", err.Error())
+	}
+	stored = s
+}
+
+// saveChangedSettings saves the settings only when they differ from the
+// stored ones, the flash wears with every write.
+func saveChangedSettings() {
+	if currentSettings() != stored {
+		saveSettings()
+	}
+}
+
+// The network settings, the provisioned ones or else the ones the firmware
+// was built with, see data.go.
+
+func wifiSSID() string     { return or(stored.WifiSSID, WifiSSID) }
+func wifiPassword() string { return or(stored.WifiPassword, WifiPassword) }
+func mqttUser() string     { return or(stored.MQTTUser, MQTTUser) }
+func mqttPassword() string { return or(stored.MQTTPassword, MQTTPassword) }
+
+// brokerAddress is the host:port of the broker, empty if there is none.
+func brokerAddress() string {
+	host := or(stored.MQTTServer, MQTTServer)
+	if host == "" {
+		return ""
+	}
+	port := MQTTPort
+	if stored.MQTTPort != 0 {
+		port = strconv.Itoa(int(stored.MQTTPort))
+	}
+	return host + ":" + port
+}
+
+func or(v, def string) string {
+	if v == "" {
+		return def
+	}
+	return v
+}
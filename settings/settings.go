@@ -0,0 +1,230 @@
+// Package settings keeps the headset configuration across reboots.
+//
+// Settings are stored as a single blob:
+//
+//	offset  size  field
+//	0       4     magic "VSET"
+//	4       1     version
+//	5       2     payload length, little endian
+//	7       n     payload
+//	7+n     4     CRC-32 (IEEE) of everything before it, little endian
+//
+// The payload is the fields of Settings in order, little endian, strings as
+// a length byte followed by their bytes. Every
+// version only appends fields to the previous one, so an older blob is
+// migrated by reading the fields it has and keeping the defaults for the
+// rest.
+package settings
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"math"
+)
+
+// Version is the version written by Encode.
+const Version = 3
+
+// MaxSize is the largest blob Encode produces, storages need to hold at
+// least this many bytes.
+const MaxSize = 512
+
+const (
+	headerSize   = 7
+	checksumSize = 4
+)
+
+// MaxString is the longest string a field can hold, longer ones are cut.
+const MaxString = 64
+
+var magic = [4]byte{'V', 'S', 'E', 'T'}
+
+var (
+	ErrEmpty    = errors.New("settings: nothing stored")
+	ErrChecksum = errors.New("settings: checksum mismatch")
+	ErrVersion  = errors.New("settings: unknown version")
+	ErrShort    = errors.New("settings: blob too short")
+)
+
+// Settings is everything the headset remembers.
+type Settings struct {
+	// Centering, see CENTERING mode.
+	OffsetHeading     int32
+	OffsetHeadingRads float64
+
+	// Magnetometer calibration ranges, see compass.Calibration.
+	MagMin, MagMax [3]int32
+
+	// Since version 2.
+
+	// Brightness of the LEDs, in percent.
+	Brightness uint8
+	// Whether to join the Wi-Fi network and the MQTT broker.
+	WifiEnabled, MQTTEnabled bool
+
+	// Since version 3, empty strings and a zero port leave the values the
+	// firmware was built with.
+
+	// Network to join.
+	WifiSSID, WifiPassword string
+	// Broker to connect to.
+	MQTTServer             string
+	MQTTPort               uint16
+	MQTTUser, MQTTPassword string
+	// DeviceID the topics of the headset live under.
+	DeviceID string
+}
+
+// Defaults returns the settings of a headset fresh out of the box.
+func Defaults() Settings {
+	var s Settings
+	for i := range s.MagMin {
+		s.MagMin[i] = math.MaxInt32
+		s.MagMax[i] = math.MinInt32
+	}
+	s.Brightness = 100
+	s.MQTTEnabled = true
+	return s
+}
+
+// Encode returns s as a blob of the current version.
+func Encode(s *Settings) []byte {
+	var w writer
+	w.buf = make([]byte, headerSize, MaxSize)
+	copy(w.buf, magic[:])
+	w.buf[4] = Version
+
+	w.i32(s.OffsetHeading)
+	w.f64(s.OffsetHeadingRads)
+	for i := range s.MagMin {
+		w.i32(s.MagMin[i])
+	}
+	for i := range s.MagMax {
+		w.i32(s.MagMax[i])
+	}
+	w.u8(s.Brightness)
+	w.bool(s.WifiEnabled)
+	w.bool(s.MQTTEnabled)
+	w.str(s.WifiSSID)
+	w.str(s.WifiPassword)
+	w.str(s.MQTTServer)
+	w.u16(s.MQTTPort)
+	w.str(s.MQTTUser)
+	w.str(s.MQTTPassword)
+	w.str(s.DeviceID)
+
+	binary.LittleEndian.PutUint16(w.buf[5:], uint16(len(w.buf)-headerSize))
+	return binary.LittleEndian.AppendUint32(w.buf, crc32.ChecksumIEEE(w.buf))
+}
+
+// Decode reads a blob of this or any previous version. On error the returned
+// settings are the defaults.
+func Decode(blob []byte) (Settings, error) {
+	s := Defaults()
+	if len(blob) == 0 {
+		return s, ErrEmpty
+	}
+	if len(blob) < headerSize+checksumSize {
+		return s, ErrShort
+	}
+	if [4]byte(blob[:4]) != magic {
+		return s, ErrEmpty
+	}
+	version := blob[4]
+	n := int(binary.LittleEndian.Uint16(blob[5:]))
+	if len(blob) < headerSize+n+checksumSize {
+		return s, ErrShort
+	}
+	sum := binary.LittleEndian.Uint32(blob[headerSize+n:])
+	if crc32.ChecksumIEEE(blob[:headerSize+n]) != sum {
+		return s, ErrChecksum
+	}
+	if version < 1 || version > Version {
+		return s, ErrVersion
+	}
+
+	decoded := s
+	r := reader{buf: blob[headerSize : headerSize+n]}
+
+	// version 1
+	decoded.OffsetHeading = r.i32()
+	decoded.OffsetHeadingRads = r.f64()
+	for i := range decoded.MagMin {
+		decoded.MagMin[i] = r.i32()
+	}
+	for i := range decoded.MagMax {
+		decoded.MagMax[i] = r.i32()
+	}
+
+	if version >= 2 {
+		decoded.Brightness = r.u8()
+		decoded.WifiEnabled = r.bool()
+		decoded.MQTTEnabled = r.bool()
+	}
+
+	if version >= 3 {
+		decoded.WifiSSID = r.str()
+		decoded.WifiPassword = r.str()
+		decoded.MQTTServer = r.str()
+		decoded.MQTTPort = r.u16()
+		decoded.MQTTUser = r.str()
+		decoded.MQTTPassword = r.str()
+		decoded.DeviceID = r.str()
+	}
+
+	if r.short {
+		return s, ErrShort
+	}
+	return decoded, nil
+}
+
+type writer struct {
+	buf []byte
+}
+
+func (w *writer) u8(v uint8) { w.buf = append(w.buf, v) }
+func (w *writer) bool(v bool) {
+	if v {
+		w.u8(1)
+	} else {
+		w.u8(0)
+	}
+}
+func (w *writer) u16(v uint16) { w.buf = binary.LittleEndian.AppendUint16(w.buf, v) }
+func (w *writer) u32(v uint32) { w.buf = binary.LittleEndian.AppendUint32(w.buf, v) }
+func (w *writer) i32(v int32)  { w.u32(uint32(v)) }
+func (w *writer) f64(v float64) {
+	w.buf = binary.LittleEndian.AppendUint64(w.buf, math.Float64bits(v))
+}
+func (w *writer) str(v string) {
+	if len(v) > MaxString {
+		v = v[:MaxString]
+	}
+	w.u8(uint8(len(v)))
+	w.buf = append(w.buf, v...)
+}
+
+type reader struct {
+	buf   []byte
+	short bool
+}
+
+func (r *reader) next(n int) []byte {
+	if len(r.buf) < n {
+		r.short = true
+		r.buf = nil
+		return make([]byte, n)
+	}
+	b := r.buf[:n]
+	r.buf = r.buf[n:]
+	return b
+}
+
+func (r *reader) u8() uint8    { return r.next(1)[0] }
+func (r *reader) bool() bool   { return r.u8() != 0 }
+func (r *reader) u16() uint16  { return binary.LittleEndian.Uint16(r.next(2)) }
+func (r *reader) u32() uint32  { return binary.LittleEndian.Uint32(r.next(4)) }
+func (r *reader) i32() int32   { return int32(r.u32()) }
+func (r *reader) f64() float64 { return math.Float64frombits(binary.LittleEndian.Uint64(r.next(8))) }
+func (r *reader) str() string  { return string(r.next(int(r.u8()))) }
@@ -0,0 +1,166 @@
+package settings
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func full() Settings {
+	return Settings{
+		OffsetHeading:     -7,
+		OffsetHeadingRads: 1.25,
+		MagMin:            [3]int32{-300, -410, -520},
+		MagMax:            [3]int32{310, 420, 530},
+		Brightness:        40,
+		WifiEnabled:       true,
+		MQTTEnabled:       false,
+		WifiSSID:          "my net",
+		WifiPassword:      "secret",
+		MQTTServer:        "mqtt.local",
+		MQTTPort:          8883,
+		MQTTUser:          "gopher",
+		MQTTPassword:      "hunter2",
+		DeviceID:          "vision7",
+	}
+}
+
+// blob wraps payload as a blob of version, the way every version has
+// written it.
+func blob(version byte, payload []byte) []byte {
+	b := append([]byte{'V', 'S', 'E', 'T', version, 0, 0}, payload...)
+	binary.LittleEndian.PutUint16(b[5:], uint16(len(payload)))
+	return binary.LittleEndian.AppendUint32(b, crc32.ChecksumIEEE(b))
+}
+
+// v1 is the payload of a version 1 blob: centering and calibration.
+func v1(s *Settings) *writer {
+	w := &writer{}
+	w.i32(s.OffsetHeading)
+	w.f64(s.OffsetHeadingRads)
+	for _, v := range s.MagMin {
+		w.i32(v)
+	}
+	for _, v := range s.MagMax {
+		w.i32(v)
+	}
+	return w
+}
+
+// v2 adds brightness and the network switches to v1.
+func v2(s *Settings) *writer {
+	w := v1(s)
+	w.u8(s.Brightness)
+	w.bool(s.WifiEnabled)
+	w.bool(s.MQTTEnabled)
+	return w
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, s := range []Settings{Defaults(), full()} {
+		got, err := Decode(Encode(&s))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != s {
+			t.Errorf("got %+v, want %+v", got, s)
+		}
+	}
+}
+
+func TestFileStorage(t *testing.T) {
+	st := FileStorage{Path: filepath.Join(t.TempDir(), "vision.settings")}
+
+	// nothing saved yet gives the defaults
+	s, err := Load(st)
+	if err != ErrEmpty || s != Defaults() {
+		t.Fatalf("empty file: got %+v, %v", s, err)
+	}
+
+	want := full()
+	if err := Save(st, &want); err != nil {
+		t.Fatal(err)
+	}
+	s, err = Load(st)
+	if err != nil || s != want {
+		t.Errorf("got %+v, %v, want %+v", s, err, want)
+	}
+}
+
+func TestCorrupted(t *testing.T) {
+	s := full()
+	good := Encode(&s)
+
+	for _, tt := range []struct {
+		name string
+		blob []byte
+		err  error
+	}{
+		{"empty", nil, ErrEmpty},
+		{"erased flash", []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, ErrEmpty},
+		{"short", good[:5], ErrShort},
+		{"cut", good[:len(good)-1], ErrShort},
+		{"bit flip", flip(good, headerSize+3), ErrChecksum},
+		{"bad checksum", flip(good, len(good)-1), ErrChecksum},
+		{"future version", blob(Version+1, v2(&s).buf), ErrVersion},
+		{"version 0", blob(0, v2(&s).buf), ErrVersion},
+		{"payload too short for its version", blob(2, v1(&s).buf), ErrShort},
+	} {
+		got, err := Decode(tt.blob)
+		if err != tt.err {
+			t.Errorf("%s: error %v, want %v", tt.name, err, tt.err)
+		}
+		if got != Defaults() {
+			t.Errorf("%s: got %+v, want the defaults", tt.name, got)
+		}
+	}
+}
+
+func flip(b []byte, i int) []byte {
+	b = append([]byte(nil), b...)
+	b[i] ^= 0x10
+	return b
+}
+
+func TestMigrate(t *testing.T) {
+	s := full()
+
+	// version 1 only had the centering and the calibration
+	want := Defaults()
+	want.OffsetHeading = s.OffsetHeading
+	want.OffsetHeadingRads = s.OffsetHeadingRads
+	want.MagMin, want.MagMax = s.MagMin, s.MagMax
+	got, err := Decode(blob(1, v1(&s).buf))
+	if err != nil || got != want {
+		t.Errorf("v1: got %+v, %v, want %+v", got, err, want)
+	}
+
+	// version 2 added brightness and the Wi-Fi and MQTT switches
+	want.Brightness = s.Brightness
+	want.WifiEnabled = s.WifiEnabled
+	want.MQTTEnabled = s.MQTTEnabled
+	got, err = Decode(blob(2, v2(&s).buf))
+	if err != nil || got != want {
+		t.Errorf("v2: got %+v, %v, want %+v", got, err, want)
+	}
+
+	// and saving it again writes the current version
+	b := Encode(&got)
+	if b[4] != Version {
+		t.Errorf("saved as version %d, want %d", b[4], Version)
+	}
+}
+
+func TestLongStringsAreCut(t *testing.T) {
+	s := Defaults()
+	s.WifiSSID = strings.Repeat("x", MaxString+10)
+	got, err := Decode(Encode(&s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.WifiSSID != s.WifiSSID[:MaxString] {
+		t.Errorf("got %d bytes, want %d", len(got.WifiSSID), MaxString)
+	}
+}
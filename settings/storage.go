@@ -0,0 +1,67 @@
+package settings
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+)
+
+// Storage is wherever the blob is kept: flash on the board, a file on a
+// host.
+type Storage interface {
+	// Load fills buf with the stored blob and returns how much of it was
+	// read. Nothing stored yet is not an error.
+	Load(buf []byte) (n int, err error)
+	// Save replaces the stored blob.
+	Save(blob []byte) error
+}
+
+// Load reads the settings from st. Missing or corrupted settings come back
+// as the defaults, along with the reason.
+func Load(st Storage) (Settings, error) {
+	buf := make([]byte, MaxSize)
+	n, err := st.Load(buf)
+	if err != nil {
+		return Defaults(), err
+	}
+	return Decode(buf[:n])
+}
+
+// Save writes s to st.
+func Save(st Storage, s *Settings) error {
+	return st.Save(Encode(s))
+}
+
+// FileStorage keeps the blob in a file.
+type FileStorage struct {
+	Path string
+}
+
+func (f FileStorage) Load(buf []byte) (int, error) {
+	b, err := os.ReadFile(f.Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return copy(buf, b), nil
+}
+
+func (f FileStorage) Save(blob []byte) error {
+	return os.WriteFile(f.Path, blob, 0o644)
+}
+
+// MemoryStorage keeps the blob in memory.
+type MemoryStorage struct {
+	Blob []byte
+}
+
+func (m *MemoryStorage) Load(buf []byte) (int, error) {
+	return copy(buf, m.Blob), nil
+}
+
+func (m *MemoryStorage) Save(blob []byte) error {
+	m.Blob = append(m.Blob[:0], blob...)
+	return nil
+}
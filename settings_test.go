@@ -0,0 +1,64 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/conejoninja/vision/settings"
+)
+
+// countingStorage counts how many times the settings are written.
+type countingStorage struct {
+	settings.MemoryStorage
+	saves int
+}
+
+func (c *countingStorage) Save(blob []byte) error {
+	c.saves++
+	return c.MemoryStorage.Save(blob)
+}
+
+func TestSaveOnlyWhenChanged(t *testing.T) {
+	b := newTestBoard(t, NORTH)
+	st := &countingStorage{}
+	storage = st
+
+	saveChangedSettings()
+	if st.saves != 0 {
+		t.Fatalf("nothing changed: %d saves", st.saves)
+	}
+
+	// a DOWN click nudges the view and saves it on release
+	b.press(t, DOWN)
+	if st.saves != 1 {
+		t.Fatalf("after a DOWN click: %d saves, want 1", st.saves)
+	}
+	b.run(t, 10)
+	saveChangedSettings()
+	if st.saves != 1 {
+		t.Errorf("nothing changed since: %d saves, want 1", st.saves)
+	}
+}
+
+func TestMenuSavesOnlyWhenChanged(t *testing.T) {
+	newTestBoard(t, NORTH)
+	st := &countingStorage{}
+	storage = st
+
+	openMenu()
+	closeMenu()
+	if st.saves != 0 {
+		t.Fatalf("menu closed without changes: %d saves", st.saves)
+	}
+
+	openMenu()
+	for i, item := range mainMenu.Items {
+		if item.Label == "BRIGHTNESS" {
+			mainMenu.Move(i)
+		}
+	}
+	mainMenu.Adjust(-1)
+	closeMenu()
+	if st.saves != 1 {
+		t.Errorf("menu closed after a change: %d saves, want 1", st.saves)
+	}
+}
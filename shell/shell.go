@@ -0,0 +1,175 @@
+// Package shell is a line based console: it reads commands from an
+// io.Reader, one per line, and runs them with their output going to an
+// io.Writer. It knows nothing about the headset, the firmware registers its
+// commands, so the parsing and the handlers run the same off the board.
+//
+// The first word of a line names the command, case does not matter. The
+// rest of the line is handed to the command as it was typed.
+package shell
+
+import (
+	"errors"
+	"io"
+	"strings"
+)
+
+var (
+	ErrUnknown = errors.New("unknown command, try HELP")
+	ErrArgs    = errors.New("wrong arguments")
+)
+
+// MaxLine is the longest line kept, what is typed past it is ignored.
+const MaxLine = 128
+
+// Handler runs a command with the rest of its line, writing its replies to
+// w.
+type Handler func(w io.Writer, args string) error
+
+// Command is something the shell can run.
+type Command struct {
+	Name string
+	// Args and Help describe the command in the HELP list.
+	Args string
+	Help string
+	Run  Handler
+}
+
+// Shell reads lines from In and writes replies to Out.
+type Shell struct {
+	// In is polled for input, its Read must not block and may return no
+	// bytes. A nil In reads nothing.
+	In  io.Reader
+	Out io.Writer
+	// Prompt is written before every line, Echo writes back what is typed
+	// for terminals that do not echo themselves.
+	Prompt string
+	Echo   bool
+
+	Commands []Command
+	// Fallback runs the lines no command matched, the whole line is
+	// passed. Without it they are reported as unknown.
+	Fallback Handler
+
+	line     []byte
+	buf      [16]byte
+	prompted bool
+	cr       bool
+}
+
+// Poll runs the lines that arrived on In since the last call.
+func (s *Shell) Poll() {
+	if s.In == nil {
+		return
+	}
+	if !s.prompted {
+		s.prompted = true
+		s.write(s.Prompt)
+	}
+	for {
+		n, err := s.In.Read(s.buf[:])
+		for _, b := range s.buf[:n] {
+			s.Feed(b)
+		}
+		if n == 0 || err != nil {
+			return
+		}
+	}
+}
+
+// Feed takes one typed byte, running the line when it ends.
+func (s *Shell) Feed(b byte) {
+	// terminals end lines with CR, LF or both
+	afterCR := s.cr
+	s.cr = b == '\r'
+	if b == '\n' && afterCR {
+		return
+	}
+	switch b {
+	case '\r', '\n':
+		if s.Echo {
+			s.write("\r\n")
+		}
+		if len(s.line) > 0 {
+			s.Run(string(s.line))
+			s.line = s.line[:0]
+		}
+		s.write(s.Prompt)
+	case '\b', 0x7f:
+		if len(s.line) > 0 {
+			s.line = s.line[:len(s.line)-1]
+			if s.Echo {
+				s.write("\b \b")
+			}
+		}
+	default:
+		if b < ' ' || len(s.line) >= MaxLine {
+			return
+		}
+		s.line = append(s.line, b)
+		if s.Echo && s.Out != nil {
+			s.Out.Write([]byte{b})
+		}
+	}
+}
+
+// Run runs a whole line, reporting the error of the command on Out.
+func (s *Shell) Run(line string) error {
+	err := s.run(line)
+	if err != nil {
+		Print(s.Out, "ERROR", err.Error())
+	}
+	return err
+}
+
+func (s *Shell) run(line string) error {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return nil
+	}
+	name, args, _ := strings.Cut(line, " ")
+	args = strings.TrimSpace(args)
+	if strings.EqualFold(name, "HELP") || name == "?" {
+		s.help()
+		return nil
+	}
+	for _, c := range s.Commands {
+		if strings.EqualFold(c.Name, name) {
+			return c.Run(s.Out, args)
+		}
+	}
+	if s.Fallback != nil {
+		return s.Fallback(s.Out, line)
+	}
+	return ErrUnknown
+}
+
+// help lists the commands.
+func (s *Shell) help() {
+	for _, c := range s.Commands {
+		usage := strings.ToUpper(c.Name)
+		if c.Args != "" {
+			usage += " " + c.Args
+		}
+		Print(s.Out, usage, "-", c.Help)
+	}
+}
+
+func (s *Shell) write(str string) {
+	if s.Out != nil && str != "" {
+		io.WriteString(s.Out, str)
+	}
+}
+
+// Print writes words to w separated by spaces, as one line.
+func Print(w io.Writer, words ...string) {
+	if w == nil {
+		return
+	}
+	for i, word := range words {
+		if i > 0 {
+			io.WriteString(w, " ")
+		}
+		io.WriteString(w, word)
+	}
+	io.WriteString(w, "\r\n")
+}
@@ -0,0 +1,165 @@
+package shell
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// typed is a non-blocking reader handing out a few bytes at a time, like a
+// UART.
+type typed struct {
+	s     string
+	chunk int
+}
+
+func (t *typed) Read(p []byte) (int, error) {
+	n := t.chunk
+	if n > len(t.s) {
+		n = len(t.s)
+	}
+	n = copy(p, t.s[:n])
+	t.s = t.s[n:]
+	return n, nil
+}
+
+// recorder returns a shell whose commands record what they ran.
+func recorder(ran *[]string) *Shell {
+	rec := func(name string) Handler {
+		return func(w io.Writer, args string) error {
+			*ran = append(*ran, name+"("+args+")")
+			return nil
+		}
+	}
+	return &Shell{
+		Commands: []Command{
+			{Name: "game", Args: "<name>", Help: "switch game", Run: rec("game")},
+			{Name: "log", Help: "show the log", Run: rec("log")},
+			{Name: "fail", Help: "always fails", Run: func(io.Writer, string) error {
+				return ErrArgs
+			}},
+		},
+	}
+}
+
+func TestLines(t *testing.T) {
+	for _, tt := range []struct {
+		name, in string
+		want     []string
+	}{
+		{"CR", "log\r", []string{"log()"}},
+		{"LF", "log\n", []string{"log()"}},
+		{"CRLF runs once", "log\r\nlog\r\n", []string{"log()", "log()"}},
+		{"blank lines", "\r\n\n\r\r\nlog\n", []string{"log()"}},
+		{"LF CR is two ends", "log\n\rlog\r", []string{"log()", "log()"}},
+		{"unfinished line waits", "log\rlo", []string{"log()"}},
+		{"backspace", "lox\bg\n", []string{"log()"}},
+		{"delete", "game maz\x7fze\n", []string{"game(maze)"}},
+		{"backspace on an empty line", "\b\b\x7flog\n", []string{"log()"}},
+		{"control characters ignored", "l\x1bo\tg\n", []string{"log()"}},
+		{"case and spaces", "  GaMe    maze  \n", []string{"game(maze)"}},
+		{"args kept as typed", "game Maze  two\n", []string{"game(Maze  two)"}},
+	} {
+		var ran []string
+		s := recorder(&ran)
+		s.In = &typed{s: tt.in, chunk: 3}
+		s.Poll()
+		if !reflect.DeepEqual(ran, tt.want) {
+			t.Errorf("%s: ran %q, want %q", tt.name, ran, tt.want)
+		}
+	}
+}
+
+func TestMaxLine(t *testing.T) {
+	var ran []string
+	s := recorder(&ran)
+	for _, b := range []byte("game " + strings.Repeat("x", MaxLine) + "\n") {
+		s.Feed(b)
+	}
+	want := "game(" + strings.Repeat("x", MaxLine-len("game ")) + ")"
+	if len(ran) != 1 || ran[0] != want {
+		t.Errorf("ran %q", ran)
+	}
+}
+
+func TestEchoAndPrompt(t *testing.T) {
+	var ran []string
+	var out strings.Builder
+	s := recorder(&ran)
+	s.Out, s.Prompt, s.Echo = &out, "> ", true
+	s.In = &typed{s: "lgo\b\bog\r\n", chunk: 16}
+	s.Poll()
+	want := "> lgo\b \b\b \bog\r\n> "
+	if out.String() != want {
+		t.Errorf("wrote %q, want %q", out.String(), want)
+	}
+
+	// nothing typed, nothing written
+	out.Reset()
+	s.Poll()
+	if out.Len() != 0 {
+		t.Errorf("idle poll wrote %q", out.String())
+	}
+}
+
+func TestRun(t *testing.T) {
+	var ran []string
+	var out strings.Builder
+	s := recorder(&ran)
+	s.Out = &out
+
+	if err := s.Run("nope"); err != ErrUnknown {
+		t.Errorf("unknown command: %v", err)
+	}
+	if err := s.Run("fail"); err != ErrArgs {
+		t.Errorf("failing command: %v", err)
+	}
+	want := "ERROR " + ErrUnknown.Error() + "\r\nERROR " + ErrArgs.Error() + "\r\n"
+	if out.String() != want {
+		t.Errorf("wrote %q, want %q", out.String(), want)
+	}
+
+	// with a fallback the line goes there whole
+	var fell string
+	errFallback := errors.New("fallback")
+	s.Fallback = func(w io.Writer, line string) error {
+		fell = line
+		return errFallback
+	}
+	if err := s.Run("  SET SSID my net "); err != errFallback || fell != "SET SSID my net" {
+		t.Errorf("fallback got %q, %v", fell, err)
+	}
+	if err := s.Run("log"); err != nil || fell != "SET SSID my net" || len(ran) != 1 {
+		t.Errorf("a known command went to the fallback: %v %q", err, ran)
+	}
+}
+
+func TestHelp(t *testing.T) {
+	var ran []string
+	var out strings.Builder
+	s := recorder(&ran)
+	s.Out = &out
+	s.Run("help")
+	want := "GAME <name> - switch game\r\n" +
+		"LOG - show the log\r\n" +
+		"FAIL - always fails\r\n"
+	if out.String() != want {
+		t.Errorf("wrote %q, want %q", out.String(), want)
+	}
+	out.Reset()
+	s.Run("?")
+	if out.String() != want {
+		t.Errorf("? wrote %q", out.String())
+	}
+}
+
+func TestNoIO(t *testing.T) {
+	// a shell without In or Out does nothing and does not crash
+	s := &Shell{}
+	s.Poll()
+	s.Feed('x')
+	s.Feed('\n')
+	Print(nil, "nothing")
+}
@@ -0,0 +1,182 @@
+package sim
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"sync"
+	"time"
+)
+
+// Terminals only report key presses, so a key counts as held for this long
+// after it was last seen. Keyboard auto-repeat keeps it held.
+const holdTime = 150 * time.Millisecond
+
+const (
+	// TurnStep is how far each q/e press turns the head, in radians.
+	TurnStep = math.Pi / 36
+	// TiltStep is how far each r/f press tilts the head up or down, in
+	// radians.
+	TiltStep = math.Pi / 36
+
+	fieldStrength = 1000
+	// gravity in µg, like the LSM303AGR reports it
+	gravity = 1000000
+	// dip is the inclination of the earth's field below the horizon
+	dip = 60 * math.Pi / 180
+)
+
+const (
+	keyUp = iota
+	keyDown
+	keyLeft
+	keyRight
+	keyCount
+)
+
+// Keyboard reads keys from the terminal and turns them into joystick, button
+// and heading input.
+type Keyboard struct {
+	mu      sync.Mutex
+	keys    [keyCount]time.Time
+	buttons []time.Time
+	heading float64
+	// pitch is positive looking up
+	pitch float64
+	quit  chan struct{}
+}
+
+// NewKeyboard starts reading keys from r for a headset with the given number
+// of buttons.
+func NewKeyboard(r io.Reader, buttons int) *Keyboard {
+	k := &Keyboard{
+		buttons: make([]time.Time, buttons),
+		quit:    make(chan struct{}),
+	}
+	go k.read(r)
+	return k
+}
+
+// Quit is closed when the user asks to leave the simulator.
+func (k *Keyboard) Quit() <-chan struct{} {
+	return k.quit
+}
+
+func (k *Keyboard) read(r io.Reader) {
+	br := bufio.NewReader(r)
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			close(k.quit)
+			return
+		}
+		now := time.Now()
+		k.mu.Lock()
+		switch b {
+		case 'x', 'X', 3: // ctrl-c does not raise a signal in raw mode
+			k.mu.Unlock()
+			close(k.quit)
+			return
+		case 0x1b:
+			// arrow keys arrive as ESC [ A..D
+			if next, _ := br.ReadByte(); next == '[' {
+				arrow, _ := br.ReadByte()
+				switch arrow {
+				case 'A':
+					k.keys[keyUp] = now
+				case 'B':
+					k.keys[keyDown] = now
+				case 'C':
+					k.keys[keyRight] = now
+				case 'D':
+					k.keys[keyLeft] = now
+				}
+			}
+		case 'w', 'W':
+			k.keys[keyUp] = now
+		case 's', 'S':
+			k.keys[keyDown] = now
+		case 'a', 'A':
+			k.keys[keyLeft] = now
+		case 'd', 'D':
+			k.keys[keyRight] = now
+		case 'q', 'Q':
+			k.heading -= TurnStep
+		case 'e', 'E':
+			k.heading += TurnStep
+		case 'r', 'R':
+			k.pitch = math.Min(k.pitch+TiltStep, math.Pi/3)
+		case 'f', 'F':
+			k.pitch = math.Max(k.pitch-TiltStep, -math.Pi/3)
+		default:
+			if i := int(b) - '1'; i >= 0 && i < len(k.buttons) {
+				k.buttons[i] = now
+			}
+		}
+		k.mu.Unlock()
+	}
+}
+
+func (k *Keyboard) held(t time.Time) bool {
+	return time.Since(t) < holdTime
+}
+
+// Len is the number of simulated buttons.
+func (k *Keyboard) Len() int {
+	return len(k.buttons)
+}
+
+// Pressed reports whether the key for button i was pressed just now.
+func (k *Keyboard) Pressed(i int) bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.held(k.buttons[i])
+}
+
+// Get returns the joystick position. Up is 0 on the Y axis and right is 0 on
+// the X axis, like the stick on the headset.
+func (k *Keyboard) Get() (x, y uint16) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	x, y = 32768, 32768
+	if k.held(k.keys[keyRight]) {
+		x = 0
+	} else if k.held(k.keys[keyLeft]) {
+		x = 65535
+	}
+	if k.held(k.keys[keyUp]) {
+		y = 0
+	} else if k.held(k.keys[keyDown]) {
+		y = 65535
+	}
+	return x, y
+}
+
+// ReadMagneticField returns the earth's field as seen by the tilted head,
+// arranged the way the magnetometer is mounted on the headset.
+func (k *Keyboard) ReadMagneticField() (x, y, z int32, err error) {
+	field := [3]float64{fieldStrength * math.Cos(dip), 0, -fieldStrength * math.Sin(dip)}
+	return k.toSensor(field)
+}
+
+// ReadAcceleration returns gravity as seen by the tilted head, arranged the
+// way the accelerometer is mounted on the headset.
+func (k *Keyboard) ReadAcceleration() (x, y, z int32, err error) {
+	return k.toSensor([3]float64{0, 0, gravity})
+}
+
+// toSensor turns a vector in world axes (x north, z up) into the axes of the
+// head, turned by heading and tilted by pitch, and then into the axes of the
+// sensor, which is mounted with its Y axis up and its Z axis forward.
+func (k *Keyboard) toSensor(v [3]float64) (x, y, z int32, err error) {
+	k.mu.Lock()
+	heading, pitch := k.heading, k.pitch
+	k.mu.Unlock()
+
+	s, c := math.Sincos(-heading)
+	v = [3]float64{c*v[0] + s*v[1], -s*v[0] + c*v[1], v[2]}
+	s, c = math.Sincos(-pitch)
+	v = [3]float64{c*v[0] - s*v[2], v[1], s*v[0] + c*v[2]}
+
+	return int32(v[1]), int32(v[2]), int32(v[0]), nil
+}
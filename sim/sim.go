@@ -0,0 +1,152 @@
+// Package sim draws the headset in a terminal and drives it from the
+// keyboard, so games can be played on a laptop:
+//
+//	go run . 2>vision.log
+//
+// The LED strip is a row of coloured dots, the OLED is drawn with half block
+// characters, and the keys in Legend move the joystick, press the buttons
+// and turn the head.
+package sim
+
+import (
+	"bytes"
+	"image/color"
+	"io"
+	"strconv"
+
+	"github.com/conejoninja/vision/hal"
+)
+
+const (
+	stripRow = 2
+	oledRow  = 4
+)
+
+// Legend explains the keys, it is printed below the OLED.
+const Legend = "arrows/wasd: joystick  q/e: turn head  r/f: tilt head  1-6: buttons (MID RIGHT LEFT DOWN UP HAND)  x: quit"
+
+// Screen is the terminal the simulator draws on.
+type Screen struct {
+	out io.Writer
+	buf bytes.Buffer
+}
+
+// NewScreen clears the terminal and prints the static parts of the layout.
+func NewScreen(out io.Writer) *Screen {
+	s := &Screen{out: out}
+	s.buf.WriteString("\x1b[2J\x1b[?25l")
+	s.moveTo(1, 1)
+	s.buf.WriteString("GOPHER VISION 3000 simulator")
+	s.moveTo(oledRow+33, 1)
+	s.buf.WriteString(Legend)
+	s.flush()
+	return s
+}
+
+// Close puts the terminal back the way it was found.
+func (s *Screen) Close() {
+	s.moveTo(oledRow+34, 1)
+	s.buf.WriteString("\x1b[0m\x1b[?25h\n")
+	s.flush()
+}
+
+func (s *Screen) moveTo(row, col int) {
+	s.buf.WriteString("\x1b[")
+	s.buf.WriteString(strconv.Itoa(row))
+	s.buf.WriteByte(';')
+	s.buf.WriteString(strconv.Itoa(col))
+	s.buf.WriteByte('H')
+}
+
+func (s *Screen) setColor(c color.RGBA, background bool) {
+	if background {
+		s.buf.WriteString("\x1b[48;2;")
+	} else {
+		s.buf.WriteString("\x1b[38;2;")
+	}
+	s.buf.WriteString(strconv.Itoa(int(c.R)))
+	s.buf.WriteByte(';')
+	s.buf.WriteString(strconv.Itoa(int(c.G)))
+	s.buf.WriteByte(';')
+	s.buf.WriteString(strconv.Itoa(int(c.B)))
+	s.buf.WriteByte('m')
+}
+
+func (s *Screen) flush() {
+	s.out.Write(s.buf.Bytes())
+	s.buf.Reset()
+}
+
+// Strip is the LED strip drawn on a single line of the screen.
+type Strip struct {
+	screen *Screen
+}
+
+// NewStrip returns an LED strip drawn on screen.
+func NewStrip(screen *Screen) *Strip {
+	return &Strip{screen: screen}
+}
+
+// off LEDs are drawn dim so the strip can still be seen on a black terminal
+var ledOff = color.RGBA{40, 40, 40, 255}
+
+func (l *Strip) WriteColors(buf []color.RGBA) error {
+	s := l.screen
+	s.moveTo(stripRow, 1)
+	for _, c := range buf {
+		if c.R == 0 && c.G == 0 && c.B == 0 {
+			c = ledOff
+		}
+		s.setColor(c, false)
+		s.buf.WriteString("● ")
+	}
+	s.buf.WriteString("\x1b[0m")
+	s.flush()
+	return nil
+}
+
+// OLED is the display, kept in memory and drawn two pixel rows per line
+// every time Display is called.
+type OLED struct {
+	*hal.Framebuffer
+	screen *Screen
+}
+
+// NewOLED returns a width x height display drawn on screen.
+func NewOLED(screen *Screen, width, height int16) *OLED {
+	return &OLED{
+		Framebuffer: hal.NewFramebuffer(width, height),
+		screen:      screen,
+	}
+}
+
+var (
+	oledOn  = color.RGBA{120, 200, 255, 255}
+	oledOff = color.RGBA{0, 0, 0, 255}
+)
+
+func (o *OLED) Display() error {
+	o.Framebuffer.Display()
+	s := o.screen
+	s.setColor(oledOn, false)
+	s.setColor(oledOff, true)
+	for y := int16(0); y < o.Height; y += 2 {
+		s.moveTo(oledRow+int(y/2), 1)
+		for x := int16(0); x < o.Width; x++ {
+			top, bottom := o.GetPixel(x, y), o.GetPixel(x, y+1)
+			switch {
+			case top && bottom:
+				s.buf.WriteString("█")
+			case top:
+				s.buf.WriteString("▀")
+			case bottom:
+				s.buf.WriteString("▄")
+			default:
+				s.buf.WriteByte(' ')
+			}
+		}
+	}
+	s.buf.WriteString("\x1b[0m")
+	s.flush()
+	return nil
+}
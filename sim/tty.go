@@ -0,0 +1,36 @@
+//go:build !tinygo
+
+package sim
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// ErrNoTerminal is returned by RawMode when stdin is not a terminal, piped
+// or redirected from a file.
+var ErrNoTerminal = errors.New("sim: stdin is not a terminal")
+
+// RawMode switches the terminal on stdin to unbuffered input without echo and
+// returns a function that restores the previous settings.
+func RawMode() (restore func(), err error) {
+	saved, err := stty("-g")
+	if err != nil {
+		return nil, ErrNoTerminal
+	}
+	if _, err := stty("raw", "-echo"); err != nil {
+		return nil, err
+	}
+	return func() {
+		stty(strings.TrimSpace(saved))
+	}, nil
+}
+
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	return string(out), err
+}
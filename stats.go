@@ -0,0 +1,58 @@
+package main
+
+import (
+	"strconv"
+	"time"
+)
+
+// statsEvery is how often the frame timing is refreshed on the OLED and
+// published, drawing the OLED is a good part of a frame.
+const statsEvery = time.Second / frameTime
+
+func ms(d time.Duration) string {
+	return strconv.Itoa(int(d/time.Millisecond)) + "." + strconv.Itoa(int(d%time.Millisecond/(100*time.Microsecond))) + "MS"
+}
+
+// updateStats runs one frame of STATS mode, reached from the menu. HAND goes
+// back to IDLE.
+func updateStats() {
+	if pressedBtn[HAND] {
+		display.ClearDisplay()
+		display.Display()
+		mode = IDLE
+		return
+	}
+	if clock.Stats.Frames%uint32(statsEvery) != 0 {
+		return
+	}
+	s := &clock.Stats
+	showLines("FRAME "+ms(s.Last)+" / "+ms(frameTime),
+		"AVG "+ms(s.Average())+" MAX "+ms(s.Max),
+		"OVERRUNS "+strconv.Itoa(int(s.Overruns))+" DROPPED "+strconv.Itoa(int(s.Dropped)),
+		"MQTT TX "+strconv.Itoa(int(outgoing.Stats.Sent))+" DROP "+strconv.Itoa(int(outgoing.Stats.Dropped)))
+}
+
+// publishStats sends the frame timing once in a while: frames, overruns and
+// dropped steps, then the last, average and max work time in microseconds,
+// then the MQTT messages sent, dropped and skipped, all big endian uint32.
+func publishStats() {
+	s := &clock.Stats
+	if s.Frames%uint32(statsEvery) != 0 {
+		return
+	}
+	data = data[:0]
+	for _, v := range []uint32{
+		s.Frames,
+		s.Overruns,
+		s.Dropped,
+		uint32(s.Last / time.Microsecond),
+		uint32(s.Average() / time.Microsecond),
+		uint32(s.Max / time.Microsecond),
+		outgoing.Stats.Sent,
+		outgoing.Stats.Dropped,
+		outgoing.Stats.Skipped,
+	} {
+		data = append(data, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+	}
+	publishData(statsTopic, &data)
+}
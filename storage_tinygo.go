@@ -0,0 +1,28 @@
+//go:build tinygo
+
+package main
+
+import (
+	"machine"
+)
+
+// flashStorage keeps the settings at the start of the flash left over after
+// the firmware.
+type flashStorage struct{}
+
+func (flashStorage) Load(buf []byte) (int, error) {
+	return machine.Flash.ReadAt(buf, 0)
+}
+
+func (flashStorage) Save(blob []byte) error {
+	erase := machine.Flash.EraseBlockSize()
+	if err := machine.Flash.EraseBlocks(0, (int64(len(blob))+erase-1)/erase); err != nil {
+		return err
+	}
+	// writes have to cover whole write blocks
+	block := machine.Flash.WriteBlockSize()
+	padded := make([]byte, (int64(len(blob))+block-1)/block*block)
+	copy(padded, blob)
+	_, err := machine.Flash.WriteAt(padded, 0)
+	return err
+}
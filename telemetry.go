@@ -0,0 +1,73 @@
+package main
+
+import (
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/conejoninja/vision/compass"
+	"github.com/conejoninja/vision/telemetry"
+)
+
+// LegacyTopics keeps publishing every value on its own topic as well as in
+// the telemetry frame, the ui app still reads them. Turn them off when
+// building with -ldflags "-X main.LegacyTopics=off".
+var LegacyTopics = "on"
+
+var (
+	legacyTopics = LegacyTopics != "off"
+
+	telemetryFrame telemetry.Frame
+	telemetryBuf   []byte
+	bootTime       = time.Now()
+)
+
+// telemetrySource is implemented by games that fill a game section of the
+// telemetry frame.
+type telemetrySource interface {
+	Telemetry(section []byte) []byte
+}
+
+// publishState sends the state of this frame: the telemetry frame and, if
+// enabled, the legacy topics.
+func publishState(current Game) {
+	if legacyTopics {
+		current.Publish()
+		data = []byte(strconv.Itoa(ledIndex))
+		publishData(orientationTopic, &data)
+		publishData(ledsTopic, &ledBytes)
+		data = []byte(strconv.Itoa(int(pitch * 180 / math.Pi)))
+		publishData(pitchTopic, &data)
+		data = []byte(strconv.Itoa(int(roll * 180 / math.Pi)))
+		publishData(rollTopic, &data)
+	}
+	publishTelemetry(current)
+}
+
+func publishTelemetry(current Game) {
+	f := &telemetryFrame
+	f.Game = uint8(game)
+	f.Seq++
+	f.Time = uint32(time.Since(bootTime) / time.Millisecond)
+	heading := tenths(compass.Wrap(headingRads))
+	if heading < 0 {
+		heading += 3600
+	}
+	f.Heading = uint16(heading % 3600)
+	f.Pitch = int16(tenths(pitch))
+	f.Roll = int16(tenths(roll))
+	f.LEDIndex = uint8(ledIndex)
+	f.Section = f.Section[:0]
+	if s, ok := current.(telemetrySource); ok {
+		f.Section = s.Telemetry(f.Section)
+	}
+	f.LEDs = ledBytes
+
+	telemetryBuf = f.Append(telemetryBuf[:0])
+	publishData(telemetryTopic, &telemetryBuf)
+}
+
+// tenths converts radians to tenths of a degree.
+func tenths(rads float64) int {
+	return int(math.Round(rads * 1800 / math.Pi))
+}
@@ -0,0 +1,98 @@
+package telemetry
+
+import (
+	"encoding/binary"
+)
+
+// Game ids of the games with a section.
+const (
+	gameCircle = 1
+	gameMaze   = 2
+)
+
+// Circle is the game section of CIRCLE, 6 bytes:
+//
+//	0  1  arc, LEDs the circle covers
+//	1  1  orientation, where the gap is
+//	2  4  radius, int32
+type Circle struct {
+	Arc         uint8
+	Orientation uint8
+	Radius      int32
+}
+
+func (c Circle) Append(b []byte) []byte {
+	b = append(b, c.Arc, c.Orientation)
+	return binary.BigEndian.AppendUint32(b, uint32(c.Radius))
+}
+
+// DecodeCircle reads the section of a CIRCLE frame.
+func DecodeCircle(b []byte) (Circle, error) {
+	if len(b) < 6 {
+		return Circle{}, ErrShort
+	}
+	return Circle{
+		Arc:         b[0],
+		Orientation: b[1],
+		Radius:      int32(binary.BigEndian.Uint32(b[2:])),
+	}, nil
+}
+
+// Maze is the game section of MAZE, 18 bytes:
+//
+//	0   4  x, int32, in maze units
+//	4   4  y, int32
+//	8   4  seed of the level, uint32, 0 is the classic maze
+//	12  1  level number, from 1
+//	13  1  flags, bit 0 set once the level is cleared
+//	14  4  time spent on the level, milliseconds, uint32
+type Maze struct {
+	X, Y    int32
+	Seed    uint32
+	Level   uint8
+	Cleared bool
+	Elapsed uint32
+}
+
+func (m Maze) Append(b []byte) []byte {
+	b = binary.BigEndian.AppendUint32(b, uint32(m.X))
+	b = binary.BigEndian.AppendUint32(b, uint32(m.Y))
+	b = binary.BigEndian.AppendUint32(b, m.Seed)
+	var flags byte
+	if m.Cleared {
+		flags |= 1
+	}
+	b = append(b, m.Level, flags)
+	return binary.BigEndian.AppendUint32(b, m.Elapsed)
+}
+
+// DecodeMaze reads the section of a MAZE frame.
+func DecodeMaze(b []byte) (Maze, error) {
+	if len(b) < 18 {
+		return Maze{}, ErrShort
+	}
+	return Maze{
+		X:       int32(binary.BigEndian.Uint32(b)),
+		Y:       int32(binary.BigEndian.Uint32(b[4:])),
+		Seed:    binary.BigEndian.Uint32(b[8:]),
+		Level:   b[12],
+		Cleared: b[13]&1 != 0,
+		Elapsed: binary.BigEndian.Uint32(b[14:]),
+	}, nil
+}
+
+// sameSection reports whether two sections of game differ in more than what
+// moves on its own every frame: the circle's radius and the maze clock.
+func sameSection(game uint8, a, b []byte) bool {
+	from, to := 0, 0
+	switch game {
+	case gameCircle:
+		from, to = 2, 6
+	case gameMaze:
+		from, to = 14, 18
+	}
+	if len(a) < to || len(b) < to {
+		return string(a) == string(b)
+	}
+	return string(a[:from]) == string(b[:from]) && string(a[to:]) == string(b[to:])
+}
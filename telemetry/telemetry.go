@@ -0,0 +1,143 @@
+// Package telemetry encodes the state of the headset into a single binary
+// frame, published once per frame on the telemetry topic.
+//
+// Every number is big endian, like the older per-value topics. Version 1 of
+// the frame is:
+//
+//	offset  size  field
+//	0       2     magic "VT"
+//	2       1     version, 1
+//	3       1     game id: 0 NORTH, 1 CIRCLE, 2 MAZE, 3 GAMEOVER
+//	4       4     sequence number, uint32, wraps around
+//	8       4     timestamp, milliseconds since boot, uint32
+//	12      2     heading, tenths of a degree from 0 to 3599, uint16
+//	14      2     pitch, tenths of a degree, int16
+//	16      2     roll, tenths of a degree, int16
+//	18      1     LED index, uint8
+//	19      1     n, length of the game section
+//	20      n     game section, see Circle and Maze
+//	20+n    1     m, number of LEDs
+//	21+n    3m    LED colours, R G B for every LED
+//
+// Decoders must ignore anything after the LED colours, later versions only
+// append to the frame.
+package telemetry
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// Version of the frame written by Append.
+const Version = 1
+
+// HeaderSize is the size of the fixed part of the frame, up to the game
+// section.
+const HeaderSize = 20
+
+var (
+	ErrMagic   = errors.New("telemetry: not a telemetry frame")
+	ErrVersion = errors.New("telemetry: unknown version")
+	ErrShort   = errors.New("telemetry: frame too short")
+)
+
+// Frame is the state of the headset at one frame.
+type Frame struct {
+	Version  uint8
+	Game     uint8
+	Seq      uint32
+	Time     uint32
+	Heading  uint16
+	Pitch    int16
+	Roll     int16
+	LEDIndex uint8
+	// Section is the game section, up to 255 bytes.
+	Section []byte
+	// LEDs holds 3 bytes per LED, up to 255 LEDs.
+	LEDs []byte
+}
+
+// Append encodes f at the end of b. The version written is always Version.
+func (f *Frame) Append(b []byte) []byte {
+	b = append(b, 'V', 'T', Version, f.Game)
+	b = binary.BigEndian.AppendUint32(b, f.Seq)
+	b = binary.BigEndian.AppendUint32(b, f.Time)
+	b = binary.BigEndian.AppendUint16(b, f.Heading)
+	b = binary.BigEndian.AppendUint16(b, uint16(f.Pitch))
+	b = binary.BigEndian.AppendUint16(b, uint16(f.Roll))
+	b = append(b, f.LEDIndex, byte(len(f.Section)))
+	b = append(b, f.Section...)
+	b = append(b, byte(len(f.LEDs)/3))
+	return append(b, f.LEDs[:len(f.LEDs)/3*3]...)
+}
+
+// Decode reads a frame from b. Section and LEDs point into b.
+func Decode(b []byte) (Frame, error) {
+	var f Frame
+	if len(b) < 3 {
+		return f, ErrShort
+	}
+	if b[0] != 'V' || b[1] != 'T' {
+		return f, ErrMagic
+	}
+	f.Version = b[2]
+	if f.Version < 1 {
+		return f, ErrVersion
+	}
+	if len(b) < HeaderSize {
+		return f, ErrShort
+	}
+	f.Game = b[3]
+	f.Seq = binary.BigEndian.Uint32(b[4:])
+	f.Time = binary.BigEndian.Uint32(b[8:])
+	f.Heading = binary.BigEndian.Uint16(b[12:])
+	f.Pitch = int16(binary.BigEndian.Uint16(b[14:]))
+	f.Roll = int16(binary.BigEndian.Uint16(b[16:]))
+	f.LEDIndex = b[18]
+	n := int(b[19])
+	b = b[HeaderSize:]
+	if len(b) < n+1 {
+		return f, ErrShort
+	}
+	f.Section = b[:n]
+	m := 3 * int(b[n])
+	b = b[n+1:]
+	if len(b) < m {
+		return f, ErrShort
+	}
+	f.LEDs = b[:m]
+	return f, nil
+}
+
+// Deadband returns a comparison for frames that only tells them apart when
+// the game, the game section or the LEDs changed, or the heading, pitch or
+// roll moved by band tenths of a degree or more. Sequence numbers,
+// timestamps and the game fields that change every frame anyway, the maze
+// clock and the circle's radius, are ignored, which is the point.
+func Deadband(band int) func(last, next []byte) bool {
+	return func(last, next []byte) bool {
+		a, errA := Decode(last)
+		b, errB := Decode(next)
+		if errA != nil || errB != nil {
+			return false
+		}
+		if a.Game != b.Game || a.LEDIndex != b.LEDIndex ||
+			!sameSection(a.Game, a.Section, b.Section) || string(a.LEDs) != string(b.LEDs) {
+			return false
+		}
+		heading := abs(int(a.Heading) - int(b.Heading))
+		if heading > 1800 {
+			heading = 3600 - heading
+		}
+		return heading < band &&
+			abs(int(a.Pitch)-int(b.Pitch)) < band &&
+			abs(int(a.Roll)-int(b.Roll)) < band
+	}
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,181 @@
+package telemetry
+
+import (
+	"bytes"
+	"testing"
+)
+
+// golden is a MAZE frame with two LEDs, every field written out by hand
+// from the table in the package documentation.
+var golden = []byte{
+	'V', 'T', 1, 2,
+	0x01, 0x02, 0x03, 0x04, // sequence
+	0x0a, 0x0b, 0x0c, 0x0d, // time
+	0x0e, 0x0f, // heading 359.9
+	0xff, 0xd3, // pitch -4.5
+	0x01, 0x2c, // roll 30.0
+	17, 18, // LED index, section length
+	0x00, 0x00, 0x01, 0xc2, // x 450
+	0xff, 0xff, 0xff, 0xff, // y -1
+	0x00, 0x00, 0x04, 0xd2, // seed 1234
+	3, 1, // level, cleared
+	0x00, 0x00, 0xfd, 0xe8, // elapsed 65000
+	2, 1, 2, 3, 4, 5, 6, // LEDs
+}
+
+var goldenMaze = Maze{X: 450, Y: -1, Seed: 1234, Level: 3, Cleared: true, Elapsed: 65000}
+
+func goldenFrame() Frame {
+	return Frame{
+		Version:  Version,
+		Game:     2,
+		Seq:      0x01020304,
+		Time:     0x0a0b0c0d,
+		Heading:  3599,
+		Pitch:    -45,
+		Roll:     300,
+		LEDIndex: 17,
+		Section:  goldenMaze.Append(nil),
+		LEDs:     []byte{1, 2, 3, 4, 5, 6},
+	}
+}
+
+func TestAppendGolden(t *testing.T) {
+	f := goldenFrame()
+	if got := f.Append(nil); !bytes.Equal(got, golden) {
+		t.Errorf("got  % x\nwant % x", got, golden)
+	}
+
+	// a partial LED is left out
+	f.LEDs = append(f.LEDs, 7)
+	if got := f.Append(nil); !bytes.Equal(got, golden) {
+		t.Errorf("with a partial LED: got % x", got)
+	}
+}
+
+func TestDecodeGolden(t *testing.T) {
+	f, err := Decode(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := goldenFrame()
+	if f.Version != want.Version || f.Game != want.Game || f.Seq != want.Seq || f.Time != want.Time ||
+		f.Heading != want.Heading || f.Pitch != want.Pitch || f.Roll != want.Roll || f.LEDIndex != want.LEDIndex ||
+		!bytes.Equal(f.Section, want.Section) || !bytes.Equal(f.LEDs, want.LEDs) {
+		t.Errorf("got %+v, want %+v", f, want)
+	}
+	m, err := DecodeMaze(f.Section)
+	if err != nil || m != goldenMaze {
+		t.Errorf("maze %+v, %v, want %+v", m, err, goldenMaze)
+	}
+
+	// a later version with more at the end still reads
+	later := append(append([]byte(nil), golden...), 0xaa, 0xbb)
+	later[2] = Version + 1
+	if f, err := Decode(later); err != nil || !bytes.Equal(f.LEDs, want.LEDs) {
+		t.Errorf("later version: %+v, %v", f, err)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		b    []byte
+		err  error
+	}{
+		{"empty", nil, ErrShort},
+		{"not telemetry", []byte("{\"id\":1}......................"), ErrMagic},
+		{"version 0", append([]byte{'V', 'T', 0}, golden[3:]...), ErrVersion},
+		{"header cut", golden[:HeaderSize-1], ErrShort},
+		{"section cut", golden[:HeaderSize+10], ErrShort},
+		{"LEDs cut", golden[:len(golden)-1], ErrShort},
+	} {
+		if _, err := Decode(tt.b); err != tt.err {
+			t.Errorf("%s: error %v, want %v", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestCircleGolden(t *testing.T) {
+	c := Circle{Arc: 12, Orientation: 40, Radius: 280}
+	want := []byte{12, 40, 0x00, 0x00, 0x01, 0x18}
+	got := c.Append(nil)
+	if !bytes.Equal(got, want) {
+		t.Errorf("got % x, want % x", got, want)
+	}
+	if back, err := DecodeCircle(got); err != nil || back != c {
+		t.Errorf("decoded %+v, %v", back, err)
+	}
+	if _, err := DecodeCircle(got[:5]); err != ErrShort {
+		t.Errorf("short section: %v", err)
+	}
+	if _, err := DecodeMaze(goldenMaze.Append(nil)[:17]); err != ErrShort {
+		t.Errorf("short maze section: %v", err)
+	}
+}
+
+func TestDeadband(t *testing.T) {
+	same := Deadband(5)
+	last := goldenFrame()
+	for _, tt := range []struct {
+		name   string
+		change func(f *Frame)
+		same   bool
+	}{
+		{"next frame", func(f *Frame) { f.Seq++; f.Time += 50 }, true},
+		{"small turn", func(f *Frame) { f.Heading = 3595 }, true},
+		{"small turn round north", func(f *Frame) { f.Heading = 2 }, true},
+		{"turn", func(f *Frame) { f.Heading = 3594 }, false},
+		{"turn round north", func(f *Frame) { f.Heading = 4 }, false},
+		{"tilt", func(f *Frame) { f.Pitch -= 5 }, false},
+		{"roll", func(f *Frame) { f.Roll += 5 }, false},
+		{"LED", func(f *Frame) { f.LEDIndex++ }, false},
+		{"colour", func(f *Frame) { f.LEDs = []byte{1, 2, 3, 4, 5, 7} }, false},
+		{"game", func(f *Frame) { f.Game = 0; f.Section = nil }, false},
+		{"maze clock", func(f *Frame) {
+			m := goldenMaze
+			m.Elapsed += 50
+			f.Section = m.Append(nil)
+		}, true},
+		{"maze step", func(f *Frame) {
+			m := goldenMaze
+			m.X++
+			f.Section = m.Append(nil)
+		}, false},
+		{"maze cleared", func(f *Frame) {
+			m := goldenMaze
+			m.Cleared = false
+			f.Section = m.Append(nil)
+		}, false},
+	} {
+		next := goldenFrame()
+		tt.change(&next)
+		if got := same(last.Append(nil), next.Append(nil)); got != tt.same {
+			t.Errorf("%s: same %v, want %v", tt.name, got, tt.same)
+		}
+	}
+}
+
+func TestDeadbandCircle(t *testing.T) {
+	same := Deadband(5)
+	frame := func(c Circle) []byte {
+		f := Frame{Game: gameCircle, Section: c.Append(nil)}
+		return f.Append(nil)
+	}
+	c := Circle{Arc: 12, Orientation: 40, Radius: 280}
+	shrunk := c
+	shrunk.Radius -= 3
+	if !same(frame(c), frame(shrunk)) {
+		t.Error("the shrinking radius alone sent a frame")
+	}
+	wider := c
+	wider.Arc++
+	if same(frame(c), frame(wider)) {
+		t.Error("a wider arc was skipped")
+	}
+	turned := c
+	turned.Orientation++
+	if same(frame(c), frame(turned)) {
+		t.Error("a turned gap was skipped")
+	}
+}
@@ -0,0 +1,94 @@
+// Package timing paces the game loop.
+package timing
+
+import (
+	"time"
+)
+
+// Clock paces a fixed timestep loop. Every frame starts with Begin, which
+// says how many steps of Step the games have to advance to keep up with the
+// wall clock, and ends with End, which says how long to wait until the next
+// frame is due. Game speed does not depend on how long a frame took.
+type Clock struct {
+	// Step is the time every update simulates.
+	Step time.Duration
+	// MaxSteps caps how many steps a late frame catches up on, the rest are
+	// dropped so a long stall does not turn into a burst of updates.
+	MaxSteps int
+
+	Stats Stats
+
+	next    time.Time
+	begin   time.Time
+	started bool
+}
+
+// Stats measures how long the work of each frame takes.
+type Stats struct {
+	Frames uint32
+	// Overruns counts frames whose work took longer than a step.
+	Overruns uint32
+	// Dropped counts the steps skipped because of MaxSteps.
+	Dropped uint32
+	// Last and Max are the work time of the last frame and of the slowest.
+	Last, Max time.Duration
+	Total     time.Duration
+}
+
+// Average returns the mean work time of a frame.
+func (s *Stats) Average() time.Duration {
+	if s.Frames == 0 {
+		return 0
+	}
+	return s.Total / time.Duration(s.Frames)
+}
+
+// Reset clears the counters.
+func (s *Stats) Reset() {
+	*s = Stats{}
+}
+
+// Begin starts a frame at now and returns how many steps are due, at least
+// one.
+func (c *Clock) Begin(now time.Time) (steps int) {
+	if !c.started {
+		c.next = now
+		c.started = true
+	}
+	c.begin = now
+	for !now.Before(c.next) {
+		steps++
+		c.next = c.next.Add(c.Step)
+	}
+	if steps == 0 {
+		// called early, run the step that is about to be due
+		steps = 1
+		c.next = c.next.Add(c.Step)
+	}
+	if c.MaxSteps > 0 && steps > c.MaxSteps {
+		c.Stats.Dropped += uint32(steps - c.MaxSteps)
+		steps = c.MaxSteps
+	}
+	return steps
+}
+
+// End finishes the frame started by Begin and returns how long to wait for
+// the next one.
+func (c *Clock) End(now time.Time) time.Duration {
+	work := now.Sub(c.begin)
+	c.Stats.Frames++
+	c.Stats.Last = work
+	c.Stats.Total += work
+	if work > c.Stats.Max {
+		c.Stats.Max = work
+	}
+	if work > c.Step {
+		c.Stats.Overruns++
+	}
+
+	wait := c.next.Sub(now)
+	if wait < 0 {
+		return 0
+	}
+	return wait
+}
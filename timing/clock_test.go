@@ -0,0 +1,115 @@
+package timing
+
+import (
+	"testing"
+	"time"
+)
+
+const step = 50 * time.Millisecond
+
+// t0 is when the tests start, every frame is given its time from it.
+var t0 = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func at(d time.Duration) time.Time { return t0.Add(d) }
+
+func TestOnTime(t *testing.T) {
+	c := Clock{Step: step, MaxSteps: 5}
+	for i := 0; i < 10; i++ {
+		start := time.Duration(i) * step
+		if steps := c.Begin(at(start)); steps != 1 {
+			t.Fatalf("frame %d: %d steps, want 1", i, steps)
+		}
+		// the rest of the frame is waited out
+		if wait := c.End(at(start + 10*time.Millisecond)); wait != 40*time.Millisecond {
+			t.Fatalf("frame %d: wait %v, want 40ms", i, wait)
+		}
+	}
+	if c.Stats.Frames != 10 || c.Stats.Overruns != 0 || c.Stats.Dropped != 0 {
+		t.Errorf("stats %+v", c.Stats)
+	}
+}
+
+func TestCatchUp(t *testing.T) {
+	c := Clock{Step: step, MaxSteps: 5}
+	c.Begin(at(0))
+	// the work took 120ms, two steps went by
+	if wait := c.End(at(120 * time.Millisecond)); wait != 0 {
+		t.Errorf("late frame waits %v", wait)
+	}
+	if steps := c.Begin(at(120 * time.Millisecond)); steps != 2 {
+		t.Errorf("%d steps after a late frame, want 2", steps)
+	}
+	// and the schedule is kept: the next frame is due at 150ms
+	if wait := c.End(at(130 * time.Millisecond)); wait != 20*time.Millisecond {
+		t.Errorf("wait %v, want 20ms", wait)
+	}
+	if steps := c.Begin(at(150 * time.Millisecond)); steps != 1 {
+		t.Errorf("%d steps back on time, want 1", steps)
+	}
+	if c.Stats.Overruns != 1 || c.Stats.Dropped != 0 {
+		t.Errorf("stats %+v", c.Stats)
+	}
+}
+
+func TestEarlyFrame(t *testing.T) {
+	c := Clock{Step: step}
+	c.Begin(at(0))
+	c.End(at(time.Millisecond))
+	// woken up early, the step about to be due runs now
+	if steps := c.Begin(at(30 * time.Millisecond)); steps != 1 {
+		t.Errorf("%d steps, want 1", steps)
+	}
+	if wait := c.End(at(31 * time.Millisecond)); wait != 69*time.Millisecond {
+		t.Errorf("wait %v, want 69ms", wait)
+	}
+}
+
+func TestMaxSteps(t *testing.T) {
+	c := Clock{Step: step, MaxSteps: 3}
+	c.Begin(at(0))
+	c.End(at(time.Millisecond))
+	// a stall of a second is 20 steps, only 3 are run
+	if steps := c.Begin(at(time.Second)); steps != 3 {
+		t.Errorf("%d steps after a stall, want 3", steps)
+	}
+	if c.Stats.Dropped != 17 {
+		t.Errorf("dropped %d steps, want 17", c.Stats.Dropped)
+	}
+	// the dropped ones are gone for good
+	c.End(at(time.Second + time.Millisecond))
+	if steps := c.Begin(at(time.Second + step)); steps != 1 {
+		t.Errorf("%d steps after the stall, want 1", steps)
+	}
+
+	// without a cap every step is run
+	c = Clock{Step: step}
+	c.Begin(at(0))
+	c.End(at(time.Millisecond))
+	if steps := c.Begin(at(time.Second)); steps != 20 {
+		t.Errorf("%d steps without a cap, want 20", steps)
+	}
+}
+
+func TestStats(t *testing.T) {
+	c := Clock{Step: step}
+	for i, work := range []time.Duration{10, 30, 70, 10} {
+		start := time.Duration(i) * 100 * time.Millisecond
+		c.Begin(at(start))
+		c.End(at(start + work*time.Millisecond))
+	}
+	s := c.Stats
+	if s.Frames != 4 || s.Overruns != 1 {
+		t.Errorf("%d frames, %d overruns, want 4 and 1", s.Frames, s.Overruns)
+	}
+	if s.Last != 10*time.Millisecond || s.Max != 70*time.Millisecond {
+		t.Errorf("last %v max %v, want 10ms and 70ms", s.Last, s.Max)
+	}
+	if avg := s.Average(); avg != 30*time.Millisecond {
+		t.Errorf("average %v, want 30ms", avg)
+	}
+
+	c.Stats.Reset()
+	if c.Stats != (Stats{}) || c.Stats.Average() != 0 {
+		t.Errorf("after Reset %+v", c.Stats)
+	}
+}
@@ -0,0 +1,82 @@
+// Package topic builds the MQTT topic names of the headsets, so the firmware
+// and the tools talking to it agree on them.
+//
+// Every headset publishes under its own prefix, Root/<device>/<name>, so
+// several of them can share a broker. A single level wildcard follows one
+// value on every headset, vision/+/leds, and a multi level one everything a
+// headset does, vision/vision3000/#.
+package topic
+
+import (
+	"strings"
+)
+
+// Root is the first level of every topic.
+const Root = "vision"
+
+// Discovery is the one topic shared by every headset, anything published on
+// it asks them to announce themselves again.
+const Discovery = Root
+
+// Names of the topics under a device.
+const (
+	Orientation       = "orientation"
+	LEDs              = "leds"
+	Pitch             = "pitch"
+	Roll              = "roll"
+	Circles           = "circles"
+	CircleArc         = "circleArc"
+	CircleOrientation = "circleOrientation"
+	CircleRadius      = "circleRadius"
+	Maze              = "maze"
+	MazeSeed          = "mazeSeed"
+	MazeTime          = "mazeTime"
+	Stats             = "stats"
+	Command           = "cmd"
+	Announce          = "announce"
+	Status            = "status"
+	Telemetry         = "telemetry"
+	Race              = "race"
+	Log               = "log"
+)
+
+// MaxDevice is the longest device ID allowed.
+const MaxDevice = 32
+
+// For returns the topic called name of device.
+func For(device, name string) string {
+	return Root + "/" + device + "/" + name
+}
+
+// Any returns a filter matching the topic called name of every device.
+func Any(name string) string {
+	return Root + "/+/" + name
+}
+
+// Device returns a filter matching every topic of device.
+func Device(device string) string {
+	return Root + "/" + device + "/#"
+}
+
+// Split takes a topic apart into the device and the name, ok is false if it
+// is not a device topic: a valid device and a name, one level each.
+func Split(t string) (device, name string, ok bool) {
+	rest, found := strings.CutPrefix(t, Root+"/")
+	if !found {
+		return "", "", false
+	}
+	device, name, found = strings.Cut(rest, "/")
+	if !found || !ValidDevice(device) || name == "" || strings.ContainsAny(name, "/+#") {
+		return "", "", false
+	}
+	return device, name, true
+}
+
+// ValidDevice reports whether id can be used as a device ID: it is a
+// single, non empty topic level without wildcards, up to MaxDevice long.
+func ValidDevice(id string) bool {
+	if id == "" || len(id) > MaxDevice {
+		return false
+	}
+	return !strings.ContainsAny(id, "/+#\x00 ")
+}
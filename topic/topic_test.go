@@ -0,0 +1,86 @@
+package topic
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFilters(t *testing.T) {
+	for _, tt := range []struct{ got, want string }{
+		{For("vision7", LEDs), "vision/vision7/leds"},
+		{For("vision7", Command), "vision/vision7/cmd"},
+		{Any(Race), "vision/+/race"},
+		{Device("vision7"), "vision/vision7/#"},
+	} {
+		if tt.got != tt.want {
+			t.Errorf("got %q, want %q", tt.got, tt.want)
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	for _, tt := range []struct {
+		in           string
+		device, name string
+		ok           bool
+	}{
+		{"vision/vision7/leds", "vision7", "leds", true},
+		{"vision/a/race", "a", "race", true},
+		// the wrong number of levels
+		{"vision", "", "", false},
+		{"vision/", "", "", false},
+		{"vision/vision7", "", "", false},
+		{"vision/vision7/", "", "", false},
+		{"vision//leds", "", "", false},
+		{"vision/vision7/leds/extra", "", "", false},
+		// filters are not topics
+		{"vision/+/leds", "", "", false},
+		{"vision/vision7/#", "", "", false},
+		{"vision/#", "", "", false},
+		// someone else's
+		{"visions/vision7/leds", "", "", false},
+		{"other/vision7/leds", "", "", false},
+		{"/vision/vision7/leds", "", "", false},
+		{"VISION/vision7/leds", "", "", false},
+		{"", "", "", false},
+	} {
+		device, name, ok := Split(tt.in)
+		if device != tt.device || name != tt.name || ok != tt.ok {
+			t.Errorf("%q: got %q %q %v, want %q %q %v", tt.in, device, name, ok, tt.device, tt.name, tt.ok)
+		}
+	}
+}
+
+func TestSplitFor(t *testing.T) {
+	for _, device := range []string{"vision7", "a", "desk-2_b.c", strings.Repeat("x", MaxDevice)} {
+		for _, name := range []string{LEDs, Race, Command, CircleOrientation} {
+			d, n, ok := Split(For(device, name))
+			if !ok || d != device || n != name {
+				t.Errorf("%s %s: split into %q %q %v", device, name, d, n, ok)
+			}
+		}
+	}
+}
+
+func TestValidDevice(t *testing.T) {
+	for _, tt := range []struct {
+		id string
+		ok bool
+	}{
+		{"vision7", true},
+		{"a", true},
+		{"ñandú", true},
+		{strings.Repeat("x", MaxDevice), true},
+		{strings.Repeat("x", MaxDevice+1), false},
+		{"", false},
+		{"a/b", false},
+		{"+", false},
+		{"vision#", false},
+		{"two words", false},
+		{"nul\x00", false},
+	} {
+		if ok := ValidDevice(tt.id); ok != tt.ok {
+			t.Errorf("%q: %v, want %v", tt.id, ok, tt.ok)
+		}
+	}
+}
@@ -7,6 +7,10 @@ import 'package:vision/config/config.dart';
 import 'package:vision/maze.dart';
 import 'dart:typed_data';
 
+// topic returns the name of a topic of the headset, vision/<device>/<name>,
+// the same as topic.For in the firmware.
+String topic(String name) => 'vision/${Config.DeviceID}/$name';
+
 void main() {
   runApp(MyApp());
 }
@@ -96,12 +100,12 @@ class _AnimatedShapeState extends State<AnimatedShape>
 
     if (_client.connectionStatus!.state == MqttConnectionState.connected) {
       print('MQTT client connected');
-      _client.subscribe('vision/orientation', MqttQos.atMostOnce);
-      _client.subscribe('vision/leds', MqttQos.atMostOnce);
-      _client.subscribe('vision/circleArc', MqttQos.atMostOnce);
-      _client.subscribe('vision/circleOrientation', MqttQos.atMostOnce);
-      _client.subscribe('vision/circleRadius', MqttQos.atMostOnce);
-      //_client.subscribe('vision/maze', MqttQos.atMostOnce);
+      _client.subscribe(topic('orientation'), MqttQos.atMostOnce);
+      _client.subscribe(topic('leds'), MqttQos.atMostOnce);
+      _client.subscribe(topic('circleArc'), MqttQos.atMostOnce);
+      _client.subscribe(topic('circleOrientation'), MqttQos.atMostOnce);
+      _client.subscribe(topic('circleRadius'), MqttQos.atMostOnce);
+      //_client.subscribe(topic('maze'), MqttQos.atMostOnce);
     } else {
       print(
           'MQTT client connection failed - disconnecting, status is ${_client.connectionStatus}');
@@ -114,7 +118,7 @@ class _AnimatedShapeState extends State<AnimatedShape>
           MqttPublishPayload.bytesToStringAsString(recMess.payload.message);
       print("TOPIC");
       print(c[0].topic);
-      if (c[0].topic == 'vision/orientation') {
+      if (c[0].topic == topic('orientation')) {
         setState(() {
           _mqttRotationFactor = double.parse(message);
           player.rotation = 2 * math.pi * (_mqttRotationFactor / 56) - math.pi;
@@ -122,7 +126,7 @@ class _AnimatedShapeState extends State<AnimatedShape>
           //print("ROTATION");
           //print(_mqttRotationFactor);
         });
-      } else if (c[0].topic == 'vision/maze') {
+      } else if (c[0].topic == topic('maze')) {
         // Convert Uint8Buffer to Uint8List
         Uint8List byteList = Uint8List.fromList(recMess.payload.message);
         ByteData byteData = ByteData.sublistView(byteList);
@@ -133,11 +137,11 @@ class _AnimatedShapeState extends State<AnimatedShape>
         print(player.x);
         print('y: ');
         print(player.y);
-      } else if (c[0].topic == 'vision/circleArc') {
+      } else if (c[0].topic == topic('circleArc')) {
         print("ARC: ");
         print(message);
         _circleArcFactor = double.parse(message);
-      } else if (c[0].topic == 'vision/circleOrientation') {
+      } else if (c[0].topic == topic('circleOrientation')) {
         setState(() {
           _circleRotationFactor = double.parse(message);
           player.rotation =
@@ -146,7 +150,7 @@ class _AnimatedShapeState extends State<AnimatedShape>
           print("ROTATION:");
           print(_circleRotationFactor);
         });
-      } else if (c[0].topic == 'vision/circleRadius') {
+      } else if (c[0].topic == topic('circleRadius')) {
         Uint8List byteList = Uint8List.fromList(recMess.payload.message);
         ByteData byteData = ByteData.sublistView(byteList);
         print('CIRCLE RADIUS: ');
@@ -1,148 +1,193 @@
 package main
 
 import (
-	"context"
-	"io"
 	"net"
+	"sync"
 	"time"
 
 	"math/rand"
 
-	mqtt "github.com/soypat/natiu-mqtt"
-	"tinygo.org/x/drivers/netlink"
-	"tinygo.org/x/drivers/netlink/probe"
+	"github.com/conejoninja/vision/command"
+	"github.com/conejoninja/vision/link"
+	"github.com/conejoninja/vision/logging"
+	"github.com/conejoninja/vision/topic"
 )
 
 // change these to connect to a different UART or pins for the ESP8266/ESP32
 var (
-	cl *mqtt.Client
-
+	// connectedWifi is whether Wi-Fi is joined, see updateNetwork.
 	connectedWifi bool
-	connectedMQTT bool
-	pubVar        mqtt.VariablesPublish
 
-	// IP address of the MQTT broker to use. Replace with your own info.
-	// const server = MQTTProtocol + "://" + MQTTServer + ":" + MQTTPort
-	server = MQTTServer + ":" + MQTTPort
+	// dialer connects with what connect last gave it.
+	dialer mqttDialer
+	// network keeps Wi-Fi and MQTT up in the background, reconnecting with
+	// a backoff when the broker goes away.
+	network = link.Supervisor{
+		Dialer:      &dialer,
+		Backoff:     link.Backoff{Min: time.Second, Max: time.Minute, Jitter: 0.5},
+		KeepAlive:   20 * time.Second,
+		PingTimeout: 10 * time.Second,
+	}
+	linkState link.State
 )
 
+// connect starts bringing the network up, it does not wait for it.
 func connect() {
-	if useWifi && WifiSSID != "" && server != "" {
-		if connectToAP() {
-			connectToMQTT()
-		}
+	if useWifi && useMQTT && wifiSSID() != "" && brokerAddress() != "" {
+		dialer.Configure(currentNetConfig())
+		network.Backoff.Seed = uint32(time.Now().UnixNano())
+		network.Start()
 	}
 }
 
-func connectToAP() bool {
-	time.Sleep(2 * time.Second)
-	for retry := 0; retry < 4; retry++ {
-		println("Connecting to " + WifiSSID)
-		link, _ := probe.Probe()
-
-		err := link.NetConnect(&netlink.ConnectParams{
-			Ssid:       WifiSSID,
-			Passphrase: WifiPassword,
-		})
-		if err != nil {
-			println("[CONNECT TO AP]", err)
-			println("Waiting 15s before trying to reconnect")
-			connectedWifi = false
-			time.Sleep(15 * time.Second)
-		} else {
-			connectedWifi = true
-			return true
-		}
+// setWifi turns the network on or off.
+func setWifi(on bool) {
+	useWifi = on
+	if !on {
+		network.Stop()
+		return
 	}
-	return false
+	connect()
 }
 
-func connectToMQTT() bool {
-	println("Connecting to MQTT")
+// setMQTT turns the connection to the broker on or off.
+func setMQTT(on bool) {
+	useMQTT = on
+	if !on {
+		network.Stop()
+		return
+	}
+	connect()
+}
 
-	clientId := MQTTClientID + randomString(10)
-	println("ClientId:", clientId)
+// updateNetwork runs the link supervisor for one frame and logs what
+// changed.
+func updateNetwork(dt time.Duration) {
+	state := network.Update(dt)
+	connectedWifi = network.Joined()
+	if state == linkState {
+		return
+	}
+	linkLog.Info(linkState.String(), "->", state.String())
+	if state == link.Up {
+		// a new session, whatever was queued is old news
+		outgoing.Reset()
+	}
+	// network errors format their message on every call to Error
+	if state == link.Waiting && network.Err() != nil && linkLog.Enabled(logging.Warn) {
+		linkLog.Warn(network.Err().Error())
+	}
+	linkState = state
+}
 
-	// Get a transport for MQTT packets
-	println("Connecting to MQTT broker at ", server)
-	conn, err := net.Dial("tcp", server)
-	if err != nil {
-		println("Error connection to MQTT", err)
-		return false
+// netConfig is everything the dialer needs. It is copied on the game loop,
+// the dialer runs on the supervisor's goroutine and must not read the
+// globals behind it.
+type netConfig struct {
+	ssid, passphrase string
+	broker           string
+	user, password   string
+	commandTopic     string
+	statusTopic      string
+	announce         []link.Message
+}
+
+// currentNetConfig returns the network settings as they are now.
+func currentNetConfig() netConfig {
+	return netConfig{
+		ssid:         wifiSSID(),
+		passphrase:   wifiPassword(),
+		broker:       brokerAddress(),
+		user:         mqttUser(),
+		password:     mqttPassword(),
+		commandTopic: commandTopic,
+		statusTopic:  statusTopic,
+		announce:     announcement(),
 	}
-	// close it somewhere else ¯\_(ツ)_/¯
-	//defer conn.Close()
-	println("Connected to MQTT")
-	// Create new client
-	cl = mqtt.NewClient(mqtt.ClientConfig{
-		Decoder: mqtt.DecoderNoAlloc{make([]byte, 1500)},
-		OnPub: func(_ mqtt.Header, _ mqtt.VariablesPublish, r io.Reader) error {
-			message, _ := io.ReadAll(r)
-			println("Message  received on topic", string(message), discoveryTopic)
-			return nil
-		},
-	})
+}
 
-	println("Connecting to client")
-	// Connect client
-	var varconn mqtt.VariablesConnect
-	varconn.SetDefaultMQTT([]byte(clientId))
-	varconn.Username = []byte(MQTTUser)
-	varconn.Password = []byte(MQTTPassword)
-	ctx, _ := context.WithTimeout(context.Background(), 10*time.Second)
-	err = cl.Connect(ctx, conn, &varconn)
+// mqttDialer joins Wi-Fi and opens MQTT sessions for the supervisor.
+type mqttDialer struct {
+	mu  sync.Mutex
+	cfg netConfig
+}
+
+// Configure sets what the next attempts connect with.
+func (d *mqttDialer) Configure(cfg netConfig) {
+	d.mu.Lock()
+	d.cfg = cfg
+	d.mu.Unlock()
+}
+
+func (d *mqttDialer) config() netConfig {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.cfg
+}
+
+func (d *mqttDialer) Network() error {
+	cfg := d.config()
+	return connectToAP(cfg.ssid, cfg.passphrase)
+}
+
+func (d *mqttDialer) Dial() (link.Session, error) {
+	cfg := d.config()
+	linkLog.Info("connecting to broker", cfg.broker)
+	conn, err := net.Dial("tcp", cfg.broker)
 	if err != nil {
-		println("failed to connect: ", err)
+		return nil, err
 	}
-	println("Connected to client")
-
-	// Subscribe to topic
-	println("Subscribing to topic", discoveryTopic)
-	ctx, _ = context.WithTimeout(context.Background(), 10*time.Second)
-	err = cl.Subscribe(ctx, mqtt.VariablesSubscribe{
-		PacketIdentifier: 23,
-		TopicFilters: []mqtt.SubscribeRequest{
-			{TopicFilter: []byte(discoveryTopic), QoS: mqtt.QoS0},
+	clientId := MQTTClientID + randomString(10)
+	linkLog.Debug("client id", clientId)
+	m, err := link.DialMQTT(conn, &link.Config{
+		ClientID:      clientId,
+		User:          cfg.user,
+		Password:      cfg.password,
+		KeepAlive:     60,
+		Subscriptions: []string{discoveryTopic, cfg.commandTopic, racersTopic},
+		OnMessage: func(t string, payload []byte) {
+			switch t {
+			case cfg.commandTopic:
+				receiveCommand(payload)
+				return
+			case discoveryTopic:
+				// a dashboard looking for headsets
+				commands.Push(command.Command{Kind: command.Announce})
+				return
+			}
+			if device, name, ok := topic.Split(t); ok && name == topic.Race {
+				receiveRace(device, payload)
+				return
+			}
+			mqttLog.Debug("unexpected message on", t)
 		},
+		Will:     link.Message{Topic: cfg.statusTopic, Payload: []byte("offline"), Retain: true},
+		Announce: cfg.announce,
 	})
 	if err != nil {
-		println("failed to subscribe to", discoveryTopic, err)
-		connectedMQTT = false
-		return false
+		return nil, err
 	}
-	println("Subscribed to topic ", discoveryTopic)
-
-	// Publish on topic
-	/*	pubFlags, _ := mqtt.NewPublishFlags(mqtt.QoS0, false, false)
-		pubVar := mqtt.VariablesPublish{
-			TopicName: []byte(topic),
-		}*/
-
-	connectedMQTT = true
-	return true
+	linkLog.Debug("subscribed to", discoveryTopic, cfg.commandTopic, racersTopic)
+	return m, nil
 }
 
-func publishDiscovery() {
-	if !connectedMQTT {
+// publishData queues data for topic, it goes out when the outbox allows.
+func publishData(topic string, data *[]byte) {
+	if network.State() != link.Up {
 		return
 	}
+	outgoing.Publish(topic, *data)
 }
 
-func publishData(topic string, data *[]byte) {
-	if !connectedMQTT {
+// publishNow sends data on topic straight away, for messages that must not
+// be merged with the next one on the same topic.
+func publishNow(topic string, data *[]byte) {
+	if network.State() != link.Up {
 		return
 	}
-
-	pubFlags, _ := mqtt.NewPublishFlags(mqtt.QoS0, false, false)
-	pubVar.TopicName = []byte(topic)
-	pubVar.PacketIdentifier++
-
-	//println("[PUBLISH DATA]", "#"+topic, "MSG TO SEND", string(*data))
-	err := cl.PublishPayload(pubFlags, pubVar, *data)
-	if err != nil {
-		println("error transmitting message: ", err)
+	if err := network.Publish(topic, *data); err != nil && mqttLog.Enabled(logging.Warn) {
+		mqttLog.Warn("publish failed", err.Error())
 	}
-
 }
 
 // Returns an int >= min, < max
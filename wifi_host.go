@@ -0,0 +1,8 @@
+//go:build !tinygo
+
+package main
+
+// connectToAP has nothing to do on a host, the OS already owns the network.
+func connectToAP(ssid, passphrase string) error {
+	return nil
+}
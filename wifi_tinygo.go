@@ -0,0 +1,37 @@
+//go:build tinygo
+
+package main
+
+import (
+	"time"
+
+	"github.com/conejoninja/vision/logging"
+	"tinygo.org/x/drivers/netlink"
+	"tinygo.org/x/drivers/netlink/probe"
+)
+
+// radioReady is set once the radio had time to boot.
+var radioReady bool
+
+// connectToAP joins the access point, the link supervisor retries it when it
+// fails.
+func connectToAP(ssid, passphrase string) error {
+	if !radioReady {
+		time.Sleep(2 * time.Second)
+		radioReady = true
+	}
+	linkLog.Info("joining", ssid)
+	link, _ := probe.Probe()
+
+	err := link.NetConnect(&netlink.ConnectParams{
+		Ssid:       ssid,
+		Passphrase: passphrase,
+	})
+	if err != nil {
+		if linkLog.Enabled(logging.Warn) {
+			linkLog.Warn("join failed", err.Error())
+		}
+		return err
+	}
+	return nil
+}